@@ -5,9 +5,6 @@ go 1.12
 replace github.com/gary163/seals => ./
 
 require (
-	github.com/gobwas/httphead v0.0.0-20180130184737-2c6c146eadee // indirect
-	github.com/gobwas/pool v0.2.0 // indirect
-	github.com/gobwas/ws v1.0.2
 	github.com/golang/protobuf v1.3.2
 	github.com/gorilla/websocket v1.4.1
 )
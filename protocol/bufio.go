@@ -32,7 +32,16 @@ func newBufio(base Protocol, readSize, writeSize int) (Protocol,error) {
 	return bp, nil
 }
 
-func (bio *bufioProtocol) Register(interface{}){}
+func (bio *bufioProtocol) Register(t interface{}){
+	bio.base.Register(t)
+}
+
+func (bio *bufioProtocol) Name(msg interface{}) (string, bool) {
+	if namer, ok := bio.base.(Namer); ok {
+		return namer.Name(msg)
+	}
+	return "", false
+}
 
 func (bio *bufioProtocol) NewCodec(rw io.ReadWriter) (code Codec,err error) {
 	codec := &bufioCodec{}
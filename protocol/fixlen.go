@@ -101,7 +101,16 @@ func (fix *fixlenProtocol) fixLen() error {
 	return nil
 }
 
-func (fix *fixlenProtocol) Register(interface{}){}
+func (fix *fixlenProtocol) Register(t interface{}){
+	fix.base.Register(t)
+}
+
+func (fix *fixlenProtocol) Name(msg interface{}) (string, bool) {
+	if namer, ok := fix.base.(Namer); ok {
+		return namer.Name(msg)
+	}
+	return "", false
+}
 
 func (fix *fixlenProtocol) NewCodec(rw io.ReadWriter) (Codec, error) {
 	c := &codec{}
@@ -167,7 +176,8 @@ func (c *codec) Receive() (interface{}, error) {
 
 func (c *codec) Send(msg interface{}) error {
 	c.sendBuf.Reset()
-	c.sendBuf.Write(c.headBuf)
+	var head [8]byte //不复用headBuf，避免与Receive并发时互相覆盖
+	c.sendBuf.Write(head[:c.protocol.n])
 	if err := c.base.Send(msg); err != nil {
 		return nil
 	}
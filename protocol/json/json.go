@@ -32,6 +32,18 @@ func (j *JsonProtocol) Register(t interface{}) {
 	j.names[rt] = name
 }
 
+func (j *JsonProtocol) Name(msg interface{}) (string, bool) {
+	t := reflect.TypeOf(msg)
+	if t == nil {
+		return "", false
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	name, exists := j.names[t]
+	return name, exists
+}
+
 func (j *JsonProtocol) NewCodec(rw io.ReadWriter) (protocol.Codec, error) {
 	codec := &jsonCodec{
 		p:       j,
@@ -43,13 +55,17 @@ func (j *JsonProtocol) NewCodec(rw io.ReadWriter) (protocol.Codec, error) {
 }
 
 type jsonIn struct {
-	Head string
-	Body *json.RawMessage
+	Head  string
+	Seq   uint64 `json:",omitempty"`
+	Reply bool   `json:",omitempty"`
+	Body  *json.RawMessage
 }
 
 type jsonOut struct {
-	Head string
-	Body interface{}
+	Head  string
+	Seq   uint64 `json:",omitempty"`
+	Reply bool   `json:",omitempty"`
+	Body  interface{}
 }
 
 type jsonCodec struct {
@@ -76,11 +92,19 @@ func (c *jsonCodec) Receive() (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
+	if in.Seq != 0 {
+		return &protocol.Correlated{ID: in.Seq, Reply: in.Reply, Body: body}, nil
+	}
 	return body, nil
 }
 
 func (c *jsonCodec) Send(msg interface{}) error {
 	var out jsonOut
+	if correlated, ok := msg.(*protocol.Correlated); ok {
+		out.Seq = correlated.ID
+		out.Reply = correlated.Reply
+		msg = correlated.Body
+	}
 	t := reflect.TypeOf(msg)
 	if t.Kind() == reflect.Ptr {
 		t = t.Elem()
@@ -103,8 +127,11 @@ func (c *jsonCodec) Close() error {
 }
 
 func init() {
-	protocol.Register("json", &JsonProtocol{
+	p := &JsonProtocol{
 		types: make(map[string]reflect.Type),
 		names: make(map[reflect.Type]string),
-	})
+	}
+	p.Register(&protocol.Ping{})
+	p.Register(&protocol.Pong{})
+	protocol.Register("json", p)
 }
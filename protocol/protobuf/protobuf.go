@@ -36,6 +36,18 @@ func (j *protobufProtocol) Register(t interface{}) {
 	fmt.Printf("names:%+v\n",j.names)
 }
 
+func (p *protobufProtocol) Name(msg interface{}) (string, bool) {
+	t := reflect.TypeOf(msg)
+	if t == nil {
+		return "", false
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	name, exists := p.names[t]
+	return name, exists
+}
+
 func (p *protobufProtocol) NewCodec(rw io.ReadWriter) (protocol.Codec, error) {
 	codec := &protobufCodec{
 		p  :  p,
@@ -48,6 +60,8 @@ func (p *protobufProtocol) NewCodec(rw io.ReadWriter) (protocol.Codec, error) {
 type in struct {
 	Head  *string   `protobuf:"bytes,1,opt,name=head,proto3" json:"head,omitempty"`
 	Body  []byte
+	Seq   uint64    `protobuf:"varint,3,opt,name=seq,proto3" json:"seq,omitempty"`
+	Reply bool      `protobuf:"varint,4,opt,name=reply,proto3" json:"reply,omitempty"`
 }
 
 func (i *in) Reset()         { *i = in{} }
@@ -55,8 +69,10 @@ func (i *in) String() string { return proto.CompactTextString(i) }
 func (*in) ProtoMessage()    {}
 
 type out struct {
-	Head *string `protobuf:"bytes,1,opt,name=head,proto3" json:"head,omitempty"`
-	Body proto.Message
+	Head  *string `protobuf:"bytes,1,opt,name=head,proto3" json:"head,omitempty"`
+	Body  proto.Message
+	Seq   uint64  `protobuf:"varint,3,opt,name=seq,proto3" json:"seq,omitempty"`
+	Reply bool    `protobuf:"varint,4,opt,name=reply,proto3" json:"reply,omitempty"`
 }
 
 func (o *out) Reset()         { *o = out{} }
@@ -87,15 +103,28 @@ func (c *protobufCodec) Receive() (interface{}, error) {
 			body = reflect.New(t).Interface()
 		}
 	}
+	//心跳消息没有消息体
+	switch body.(type) {
+	case *protocol.Ping, *protocol.Pong:
+		return body, nil
+	}
 	err = proto.Unmarshal(in.Body, body.(proto.Message))
 	if err != nil {
 		return nil, err
 	}
+	if in.Seq != 0 {
+		return &protocol.Correlated{ID: in.Seq, Reply: in.Reply, Body: body}, nil
+	}
 	return body, nil
 }
 
 func (c *protobufCodec) Send(msg interface{}) error {
 	var out out
+	if correlated, ok := msg.(*protocol.Correlated); ok {
+		out.Seq = correlated.ID
+		out.Reply = correlated.Reply
+		msg = correlated.Body
+	}
 	t := reflect.TypeOf(msg)
 	if t.Kind() == reflect.Ptr {
 
@@ -107,7 +136,11 @@ func (c *protobufCodec) Send(msg interface{}) error {
 		out.Head = proto.String(name)
 	}
 	fmt.Printf("name:%v\n",out.Head)
-	out.Body = msg.(proto.Message)
+	switch msg.(type) {
+	case *protocol.Ping, *protocol.Pong:
+	default:
+		out.Body = msg.(proto.Message)
+	}
 	fmt.Println(out.Body )
 
 	outBytes,err := proto.Marshal(&out)
@@ -129,8 +162,11 @@ func (c *protobufCodec) Close() error {
 }
 
 func init() {
-	protocol.Register("protobuf", &protobufProtocol{
+	p := &protobufProtocol{
 		types: make(map[string]reflect.Type),
 		names: make(map[reflect.Type]string),
-	})
+	}
+	p.Register(&protocol.Ping{})
+	p.Register(&protocol.Pong{})
+	protocol.Register("protobuf", p)
 }
@@ -22,6 +22,24 @@ type Codec interface {
 	Close() error
 }
 
+//带关联ID的消息，Session.Call用它来匹配请求和应答
+//支持的codec(json,protobuf)会把ID和Reply写进消息头，接收时再还原成*Correlated
+type Correlated struct {
+	ID    uint64
+	Reply bool
+	Body  interface{}
+}
+
+//框架内置的心跳消息，Session收到Ping会自动回Pong，两者都不会交给handler
+//json,protobuf协议已内置注册
+type Ping struct{}
+type Pong struct{}
+
+//可以根据消息类型查出消息名(即编码时的Head)的协议，json,protobuf实现了该接口
+type Namer interface {
+	Name(msg interface{}) (string, bool)
+}
+
 var (
 	procotolMux sync.RWMutex
 	adapters = make(map[string]Protocol)
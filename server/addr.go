@@ -0,0 +1,27 @@
+package server
+
+import (
+	"net"
+)
+
+type localAddrKey struct{}
+type remoteAddrKey struct{}
+
+//由NewSessionWithInfo根据ConnInfo设置，经过代理时应为真实的客户端和目的地址
+func (s *Session) setAddr(local, remote net.Addr) {
+	s.SetAttr(localAddrKey{}, local)
+	s.SetAttr(remoteAddrKey{}, remote)
+}
+
+//未设置时返回nil
+func (s *Session) LocalAddr() net.Addr {
+	value, _ := s.Attr(localAddrKey{})
+	addr, _ := value.(net.Addr)
+	return addr
+}
+
+func (s *Session) RemoteAddr() net.Addr {
+	value, _ := s.Attr(remoteAddrKey{})
+	addr, _ := value.(net.Addr)
+	return addr
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"context"
+)
+
+//设置Session的属性，如用户ID，设备信息，认证状态等，并发安全
+func (s *Session) SetAttr(key, value interface{}) {
+	s.attrMu.Lock()
+	defer s.attrMu.Unlock()
+	if s.attrs == nil {
+		s.attrs = make(map[interface{}]interface{})
+	}
+	s.attrs[key] = value
+}
+
+func (s *Session) Attr(key interface{}) (interface{}, bool) {
+	s.attrMu.RLock()
+	defer s.attrMu.RUnlock()
+	value, ok := s.attrs[key]
+	return value, ok
+}
+
+func (s *Session) DelAttr(key interface{}) {
+	s.attrMu.Lock()
+	defer s.attrMu.Unlock()
+	delete(s.attrs, key)
+}
+
+//属性不存在或类型不是string时ok为false
+func (s *Session) AttrString(key interface{}) (string, bool) {
+	value, _ := s.Attr(key)
+	v, ok := value.(string)
+	return v, ok
+}
+
+func (s *Session) AttrInt64(key interface{}) (int64, bool) {
+	value, _ := s.Attr(key)
+	v, ok := value.(int64)
+	return v, ok
+}
+
+func (s *Session) AttrBool(key interface{}) (bool, bool) {
+	value, _ := s.Attr(key)
+	v, ok := value.(bool)
+	return v, ok
+}
+
+//与Session生命周期绑定的Context，Session关闭时被取消
+func (s *Session) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+type userKey struct{}
+
+func TestAttr(t *testing.T) {
+	session := NewSession(newTestCodec(), 0)
+	defer session.Close()
+
+	if _, ok := session.Attr(userKey{}); ok {
+		t.Fatal("Attr found a key that was never set")
+	}
+	session.SetAttr(userKey{}, "alice")
+	session.SetAttr("uid", int64(1001))
+	session.SetAttr("admin", true)
+
+	if v, ok := session.AttrString(userKey{}); !ok || v != "alice" {
+		t.Fatalf("AttrString = %q, %v\n", v, ok)
+	}
+	if v, ok := session.AttrInt64("uid"); !ok || v != 1001 {
+		t.Fatalf("AttrInt64 = %d, %v\n", v, ok)
+	}
+	if v, ok := session.AttrBool("admin"); !ok || !v {
+		t.Fatalf("AttrBool = %v, %v\n", v, ok)
+	}
+
+	//类型不匹配或不存在时ok为false，返回零值
+	if v, ok := session.AttrInt64(userKey{}); ok || v != 0 {
+		t.Fatalf("AttrInt64 on a string = %d, %v\n", v, ok)
+	}
+	if v, ok := session.AttrString("uid"); ok || v != "" {
+		t.Fatalf("AttrString on an int64 = %q, %v\n", v, ok)
+	}
+	if v, ok := session.AttrBool("missing"); ok || v {
+		t.Fatalf("AttrBool on a missing key = %v, %v\n", v, ok)
+	}
+	//key的类型不同即使值相同也是不同的属性
+	if _, ok := session.Attr(userKey{}); !ok {
+		t.Fatal("struct key not found")
+	}
+	if _, ok := session.Attr("userKey"); ok {
+		t.Fatal("string key matched a struct key")
+	}
+
+	session.DelAttr(userKey{})
+	if _, ok := session.AttrString(userKey{}); ok {
+		t.Fatal("Attr found a deleted key")
+	}
+}
+
+func TestAttrConcurrent(t *testing.T) {
+	session := NewSession(newTestCodec(), 0)
+	defer session.Close()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := strconv.Itoa(g) + ":" + strconv.Itoa(i%10)
+				session.SetAttr(key, int64(i))
+				if _, ok := session.AttrInt64(key); !ok {
+					t.Errorf("key %s missing right after SetAttr\n", key)
+					return
+				}
+				session.Attr(strconv.Itoa((g+1)%8) + ":0")
+				if i%100 == 0 {
+					session.DelAttr(key)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestContext(t *testing.T) {
+	reason := errors.New("kicked")
+	for _, closeFn := range []func(*Session) error{
+		(*Session).Close,
+		func(s *Session) error { return s.CloseWithReason(reason) },
+	} {
+		session := NewSession(newTestCodec(), 0)
+		ctx := session.Context()
+		select {
+		case <-ctx.Done():
+			t.Fatal("Context done before Close")
+		default:
+		}
+		closeFn(session)
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+			t.Fatal("Context not cancelled by Close")
+		}
+		if session.Context() != ctx {
+			t.Fatal("Context changed after Close")
+		}
+	}
+}
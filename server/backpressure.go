@@ -0,0 +1,102 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+//sendChan满时的处理策略
+type SendPolicy int
+
+const (
+	SendPolicyClose      SendPolicy = iota //关闭Session，默认策略
+	SendPolicyBlock                        //阻塞等待，超过SendTimeout则丢弃该消息
+	SendPolicyDropNewest                   //丢弃当前要发送的消息
+	SendPolicyDropOldest                   //丢弃队列中最早的消息，再放入当前消息
+)
+
+var SendTimeoutError = errors.New("Session Send Timeout")
+var SendDroppedError = errors.New("Session Send Dropped")
+
+//配置中的策略名：close,block,dropNewest,dropOldest
+func ParseSendPolicy(name string) (SendPolicy, error) {
+	switch name {
+	case "", "close":
+		return SendPolicyClose, nil
+	case "block":
+		return SendPolicyBlock, nil
+	case "dropNewest":
+		return SendPolicyDropNewest, nil
+	case "dropOldest":
+		return SendPolicyDropOldest, nil
+	}
+	return SendPolicyClose, fmt.Errorf("Session:unknown sendChanPolicy %q", name)
+}
+
+//设置消息因sendChan满被丢弃时的回调
+func (s *Session) SetDropCallback(callback func(session *Session, msg interface{})) {
+	s.dropCallback.Store(callback)
+}
+
+//sendChan中还未发出的消息数，同步发送(sendChanSize为0)时总是0
+func (s *Session) Pending() int {
+	return len(s.sendChan)
+}
+
+func (s *Session) dropped(msg interface{}, err error) {
+	if callback, ok := s.dropCallback.Load().(func(*Session, interface{})); ok && callback != nil {
+		callback(s, msg)
+	}
+	if s.sm != nil {
+		s.sm.sendDropped(s, msg, err)
+		s.sm.sendError(s, msg, err)
+	}
+}
+
+//持有sendMu读锁时调用，按策略把消息放入sendChan
+func (s *Session) enqueue(msg interface{}) error {
+	select {
+	case s.sendChan <- msg:
+		return nil
+	default:
+	}
+
+	switch s.opts.SendPolicy {
+	case SendPolicyBlock:
+		var timeoutChan <-chan time.Time
+		if s.opts.SendTimeout > 0 {
+			timer := time.NewTimer(s.opts.SendTimeout)
+			defer timer.Stop()
+			timeoutChan = timer.C
+		}
+		select {
+		case s.sendChan <- msg:
+			return nil
+		case <-s.closeChan:
+			return SessionClosedError
+		case <-timeoutChan:
+			s.dropped(msg, SendTimeoutError)
+			return SendTimeoutError
+		}
+	case SendPolicyDropNewest:
+		s.dropped(msg, SendDroppedError)
+		return SendDroppedError
+	case SendPolicyDropOldest:
+		for {
+			select {
+			case old := <-s.sendChan:
+				s.dropped(old, SendDroppedError)
+			default:
+			}
+			select {
+			case s.sendChan <- msg:
+				return nil
+			default:
+			}
+		}
+	default:
+		s.dropped(msg, SessionBlockedError)
+		return SessionBlockedError
+	}
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSendPolicy(t *testing.T) {
+	type drop struct {
+		msg interface{}
+		err error
+	}
+	tests := []struct {
+		policy   string
+		timeout  time.Duration
+		sendErr  error         //队列满时第三条消息Send的返回值
+		dropped  []drop        //OnSendDropped收到的消息
+		received []interface{} //解除阻塞后codec收到的消息
+		closed   bool
+	}{
+		//关闭时sendChan中的2会被发出，已经在codec中的1与关闭竞争，不做检查
+		{"close", 0, SessionBlockedError, []drop{{3, SessionBlockedError}}, []interface{}{2}, true},
+		{"block", 20 * time.Millisecond, SendTimeoutError, []drop{{3, SendTimeoutError}}, []interface{}{1, 2}, false},
+		{"dropNewest", 0, SendDroppedError, []drop{{3, SendDroppedError}}, []interface{}{1, 2}, false},
+		{"dropOldest", 0, nil, []drop{{2, SendDroppedError}}, []interface{}{1, 3}, false},
+	}
+	for _, tt := range tests {
+		policy, err := ParseSendPolicy(tt.policy)
+		if err != nil {
+			t.Fatalf("ParseSendPolicy(%q) err:%v\n", tt.policy, err)
+		}
+
+		var mu sync.Mutex
+		var hooked, called []drop
+		sm := NewSessionManager()
+		sm.OnSendDropped(func(session *Session, msg interface{}, err error) {
+			mu.Lock()
+			hooked = append(hooked, drop{msg, err})
+			mu.Unlock()
+		})
+		codec := newTestCodec()
+		codec.block = make(chan struct{})
+		session := sm.NewSessionWithOptions(codec, SessionOptions{
+			SendChanSize: 1,
+			SendPolicy:   policy,
+			SendTimeout:  tt.timeout,
+			DropCallback: func(session *Session, msg interface{}) {
+				mu.Lock()
+				called = append(called, drop{msg, nil})
+				mu.Unlock()
+			},
+		})
+
+		//第一条消息被sendLoop取出并阻塞在codec中，第二条占满sendChan
+		session.Send(1)
+		for session.Pending() != 0 {
+			time.Sleep(time.Millisecond)
+		}
+		if err := session.Send(2); err != nil {
+			t.Fatalf("%s: Send(2) err:%v\n", tt.policy, err)
+		}
+		if tt.closed {
+			//关闭时会先发完sendChan中的消息
+			time.AfterFunc(20*time.Millisecond, func() { close(codec.block) })
+		}
+		if err := session.Send(3); err != tt.sendErr {
+			t.Fatalf("%s: Send(3) err:%v, want %v\n", tt.policy, err, tt.sendErr)
+		}
+		if !tt.closed {
+			close(codec.block)
+		}
+
+		var received []interface{}
+		for len(received) < len(tt.received) {
+			select {
+			case msg := <-codec.sent:
+				if tt.closed && msg == 1 {
+					continue
+				}
+				received = append(received, msg)
+			case <-time.After(time.Second):
+				t.Fatalf("%s: received %v, want %v\n", tt.policy, received, tt.received)
+			}
+		}
+		for i := range received {
+			if received[i] != tt.received[i] {
+				t.Fatalf("%s: received %v, want %v\n", tt.policy, received, tt.received)
+			}
+		}
+		if session.isClosed() != tt.closed {
+			t.Fatalf("%s: session closed = %v, want %v\n", tt.policy, session.isClosed(), tt.closed)
+		}
+
+		mu.Lock()
+		if len(hooked) != len(tt.dropped) || len(called) != len(tt.dropped) {
+			t.Fatalf("%s: dropped %v / %v, want %v\n", tt.policy, hooked, called, tt.dropped)
+		}
+		for i, d := range tt.dropped {
+			if hooked[i] != d || called[i].msg != d.msg {
+				t.Fatalf("%s: dropped %v / %v, want %v\n", tt.policy, hooked, called, tt.dropped)
+			}
+		}
+		mu.Unlock()
+		sm.Destroy()
+	}
+}
+
+//阻塞策略没有超时时一直等到sendChan有空位
+func TestSendPolicyBlockWaits(t *testing.T) {
+	sm := NewSessionManager()
+	defer sm.Destroy()
+	codec := newTestCodec()
+	codec.block = make(chan struct{})
+	session := sm.NewSessionWithOptions(codec, SessionOptions{SendChanSize: 1, SendPolicy: SendPolicyBlock})
+
+	session.Send(1)
+	for session.Pending() != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	session.Send(2)
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Send(3)
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("Send returned %v while the queue is full\n", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(codec.block)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Send err:%v\n", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Send still blocked after the queue drained")
+	}
+
+	if _, err := ParseSendPolicy("fast"); err == nil {
+		t.Fatal("unknown policy should fail")
+	}
+}
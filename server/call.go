@@ -0,0 +1,137 @@
+package server
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/gary163/seals/protocol"
+)
+
+var CallTimeoutError = errors.New("Session Call Timeout")
+
+//对端通过Call发来的请求，处理完后用Reply应答
+type Request struct {
+	ID      uint64
+	Body    interface{}
+	session *Session
+}
+
+func (r *Request) Reply(msg interface{}) error {
+	return r.session.Send(&protocol.Correlated{ID: r.ID, Reply: true, Body: msg})
+}
+
+type received struct {
+	msg interface{}
+	err error
+}
+
+//发送请求并等待对端的应答，timeout<=0表示一直等待
+//应答一般由handler的Receive循环读到并转交过来；如果此时没有goroutine在Receive，Call会自己读取，
+//读到的其他消息会保留给下一次Receive，不会丢失
+func (s *Session) Call(req interface{}, timeout time.Duration) (interface{}, error) {
+	id := atomic.AddUint64(&s.callID, 1)
+	wait := make(chan interface{}, 1)
+
+	s.callMu.Lock()
+	s.calls[id] = wait
+	s.callMu.Unlock()
+	defer func() {
+		s.callMu.Lock()
+		delete(s.calls, id)
+		s.callMu.Unlock()
+	}()
+
+	if err := s.Send(&protocol.Correlated{ID: id, Body: req}); err != nil {
+		return nil, err
+	}
+
+	var timeoutChan <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutChan = timer.C
+	}
+
+	var pumped chan error
+	for {
+		var recvLock chan struct{}
+		if pumped == nil {
+			recvLock = s.recvLock
+		}
+
+		select {
+		case reply := <-wait:
+			return reply, nil
+		case recvLock <- struct{}{}:
+			pumped = make(chan error, 1)
+			go func(done chan error) {
+				done <- s.pump()
+			}(pumped)
+		case err := <-pumped:
+			pumped = nil
+			if err != nil {
+				return nil, err
+			}
+		case <-timeoutChan:
+			return nil, CallTimeoutError
+		case <-s.closeChan:
+			return nil, SessionClosedError
+		}
+	}
+}
+
+//持有接收锁时读取一条消息，应答交给等待的Call，其他消息放入接收队列
+func (s *Session) pump() error {
+	defer func() { <-s.recvLock }()
+
+	msg, err := s.receive()
+	if err != nil {
+		s.pushReceived(received{err: err})
+		return err
+	}
+	if msg, ok := s.dispatchReceived(msg); ok {
+		s.pushReceived(received{msg: msg})
+	}
+	return nil
+}
+
+//处理带关联ID的消息：应答转交给Call并返回false，请求包装成*Request
+func (s *Session) dispatchReceived(msg interface{}) (interface{}, bool) {
+	correlated, ok := msg.(*protocol.Correlated)
+	if !ok {
+		return msg, true
+	}
+
+	if !correlated.Reply {
+		return &Request{ID: correlated.ID, Body: correlated.Body, session: s}, true
+	}
+
+	s.callMu.Lock()
+	wait, ok := s.calls[correlated.ID]
+	s.callMu.Unlock()
+	if ok {
+		select {
+		case wait <- correlated.Body:
+		default: //重复的应答直接丢弃
+		}
+	}
+	return nil, false
+}
+
+func (s *Session) pushReceived(r received) {
+	s.callMu.Lock()
+	defer s.callMu.Unlock()
+	s.recvQueue = append(s.recvQueue, r)
+}
+
+func (s *Session) popReceived() (received, bool) {
+	s.callMu.Lock()
+	defer s.callMu.Unlock()
+	if len(s.recvQueue) == 0 {
+		return received{}, false
+	}
+	r := s.recvQueue[0]
+	s.recvQueue = s.recvQueue[1:]
+	return r, true
+}
@@ -6,6 +6,8 @@ import (
 
 type KEY interface{}
 
+const defaultBroadcastWorkers = 16
+
 type channel struct {
 	mu sync.RWMutex
 	sessions map[KEY]*Session
@@ -66,6 +68,75 @@ func (c *channel) Fetch(callback func(session *Session)){
 	}
 }
 
+type BroadcastOptions struct {
+	Workers int     //并发发送的goroutine数，<=0时使用defaultBroadcastWorkers
+	Exclude []int64 //不发送的Session ID
+}
+
+//广播结果，Failed记录发送失败的Session ID及原因
+type BroadcastReport struct {
+	Total  int
+	Sent   int
+	Failed map[int64]error
+}
+
+//并发广播消息，先对成员做快照，发送时不持有channel的锁，opts可以为nil
+func (c *channel) Broadcast(msg interface{}, opts *BroadcastOptions) *BroadcastReport {
+	workers := defaultBroadcastWorkers
+	exclude := make(map[int64]struct{})
+	if opts != nil {
+		if opts.Workers > 0 {
+			workers = opts.Workers
+		}
+		for _,id := range opts.Exclude {
+			exclude[id] = struct{}{}
+		}
+	}
+
+	c.mu.RLock()
+	sessions := make([]*Session, 0, len(c.sessions))
+	for _,session := range c.sessions {
+		if session == nil {
+			continue
+		}
+		if _,ok := exclude[session.ID()]; !ok {
+			sessions = append(sessions, session)
+		}
+	}
+	c.mu.RUnlock()
+
+	report := &BroadcastReport{Total: len(sessions), Failed: make(map[int64]error)}
+	if workers > len(sessions) {
+		workers = len(sessions)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	jobs := make(chan *Session)
+	for i:=0; i<workers; i++ {
+		wg.Add(1)
+		go func(){
+			defer wg.Done()
+			for session := range jobs {
+				err := session.Send(msg)
+				mu.Lock()
+				if err != nil {
+					report.Failed[session.ID()] = err
+				}else{
+					report.Sent++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _,session := range sessions {
+		jobs <- session
+	}
+	close(jobs)
+	wg.Wait()
+	return report
+}
+
 func (c *channel) Destroy(){
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -26,19 +26,26 @@ func RegisterClient(name string, adapter Client) {
 		panic("Client:adapter is nil")
 	}
 
-	if _,ok := adapters[name]; ok {
+	if _,ok := clientAdapters[name]; ok {
 		panic("Client:Register called twice for adapter" +name)
 	}
 	clientAdapters[name] = adapter
 }
 
 func NewClient(name string, config string, protocol protocol.Protocol, handler Handler) (Client, error){
+	return NewClientWithManager(name, config, protocol, handler, NewSessionManager())
+}
+
+//使用指定的SessionManager创建Client，可以先在sm上注册钩子
+func NewClientWithManager(name string, config string, protocol protocol.Protocol, handler Handler, sm *SessionManager) (Client, error){
+	clientAdpatersMu.RLock()
 	adapter, ok := clientAdapters[name]
+	clientAdpatersMu.RUnlock()
 	if !ok {
 		err := fmt.Errorf("Client: unknown adapter name %q (forgot to import?)", name)
 		return nil,err
 	}
-	sm := NewSessionManager()
+	adapter = newInstance(adapter).(Client)
 	err := adapter.Init(config, protocol, handler, sm)
 	if err != nil {
 		return nil,err
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"io"
+	"sync"
+)
+
+//测试用的codec，Send的消息放进sent，Receive从recv读取
+//block不为nil时Send一直阻塞到block可读或codec关闭
+type testCodec struct {
+	sent   chan interface{}
+	recv   chan interface{}
+	block  chan struct{}
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newTestCodec() *testCodec {
+	return &testCodec{
+		sent:   make(chan interface{}, 1024),
+		recv:   make(chan interface{}, 1024),
+		closed: make(chan struct{}),
+	}
+}
+
+func (c *testCodec) Receive() (interface{}, error) {
+	select {
+	case msg := <-c.recv:
+		return msg, nil
+	case <-c.closed:
+		return nil, io.EOF
+	}
+}
+
+func (c *testCodec) Send(msg interface{}) error {
+	if c.block != nil {
+		select {
+		case <-c.block:
+		case <-c.closed:
+			return io.ErrClosedPipe
+		}
+	}
+	select {
+	case c.sent <- msg:
+		return nil
+	case <-c.closed:
+		return io.ErrClosedPipe
+	}
+}
+
+func (c *testCodec) Close() error {
+	c.once.Do(func() {
+		close(c.closed)
+	})
+	return nil
+}
+
+//取出已经发出的消息
+func (c *testCodec) drain() []interface{} {
+	var msgs []interface{}
+	for {
+		select {
+		case msg := <-c.sent:
+			msgs = append(msgs, msg)
+		default:
+			return msgs
+		}
+	}
+}
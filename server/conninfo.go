@@ -0,0 +1,62 @@
+package server
+
+import (
+	"crypto/tls"
+	"net"
+	"time"
+
+	"github.com/gary163/seals/protocol"
+)
+
+//Session底层连接的只读信息，各个Server/Client创建Session时填写
+type ConnInfo struct {
+	Transport   string               //适配器的传输方式，如"tcp","udp","websocket"
+	LocalAddr   net.Addr             //未知时为nil
+	RemoteAddr  net.Addr             //经过代理时为真实的客户端地址
+	TLS         *tls.ConnectionState //未使用TLS时为nil
+	ConnectedAt time.Time            //连接建立的时间
+	//创建时一起设置的Session属性，如unix的对端凭证，钩子中可以取到
+	//只在创建Session时使用，Session.ConnInfo不返回
+	Attrs map[interface{}]interface{}
+}
+
+type transportKey struct{}
+
+//创建Session并在触发OnSessionCreated钩子之前填好连接信息
+//info.ConnectedAt为零值时使用当前时间
+func (sm *SessionManager) NewSessionWithInfo(codec protocol.Codec, opts SessionOptions, info ConnInfo) *Session {
+	session := newSession(codec, opts, sm)
+	if !info.ConnectedAt.IsZero() {
+		session.connectedAt = info.ConnectedAt
+	}
+	if info.Transport != "" {
+		session.SetAttr(transportKey{}, info.Transport)
+	}
+	if info.LocalAddr != nil || info.RemoteAddr != nil {
+		session.setAddr(info.LocalAddr, info.RemoteAddr)
+	}
+	if info.TLS != nil {
+		session.setTLSState(*info.TLS)
+	}
+	for key, value := range info.Attrs {
+		session.SetAttr(key, value)
+	}
+	sm.add(session)
+	return session
+}
+
+//返回连接信息的副本，修改它不影响Session
+func (s *Session) ConnInfo() ConnInfo {
+	info := ConnInfo{
+		LocalAddr:   s.LocalAddr(),
+		RemoteAddr:  s.RemoteAddr(),
+		ConnectedAt: s.connectedAt,
+	}
+	if value, ok := s.Attr(transportKey{}); ok {
+		info.Transport, _ = value.(string)
+	}
+	if state, ok := s.TLSState(); ok {
+		info.TLS = &state
+	}
+	return info
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ServerStoppedError = errors.New("Server Stopped")
+
+const drainCheckInterval = 10 * time.Millisecond
+
+//Drain因ctx到期强制关闭Session后，再给handler退出的时间
+const handlerGracePeriod = 500 * time.Millisecond
+
+//支持优雅关闭的Server
+type GracefulServer interface {
+	Server
+	//注册关闭时发给每个Session的"服务即将关闭"消息，nil表示不发送
+	SetGoingAway(msg interface{})
+	//停止accept，等待Session的发送队列清空、handler退出，ctx到期后强制关闭剩余的Session
+	//返回被强制关闭的Session数
+	StopGracefully(ctx context.Context) (int, error)
+}
+
+//给所有Session发送goingAway(为nil则不发)，等待Session自行关闭
+//ctx到期后强制关闭剩余的Session，返回被强制关闭的数量
+//Drain开始后新建的Session会被直接关闭
+func (sm *SessionManager) Drain(ctx context.Context, goingAway interface{}) int {
+	sm.mu.Lock()
+	sm.stopped = true
+	sm.mu.Unlock()
+
+	if goingAway != nil {
+		for _, session := range sm.snapshot() {
+			session.Send(goingAway)
+		}
+	}
+
+	ticker := time.NewTicker(drainCheckInterval)
+	defer ticker.Stop()
+	for sm.Len() > 0 {
+		select {
+		case <-ctx.Done():
+			n := 0
+			for _, session := range sm.snapshot() {
+				if closed, _ := session.closeWithReason(ServerStoppedError); closed {
+					n++
+				}
+			}
+			sm.Destroy()
+			return n
+		case <-ticker.C:
+		}
+	}
+	sm.Destroy()
+	return 0
+}
+
+func (sm *SessionManager) snapshot() []*Session {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	sessions := make([]*Session, 0, len(sm.sessions))
+	for _, session := range sm.sessions {
+		sessions = append(sessions, session)
+	}
+	return sessions
+}
+
+//记录正在运行的handler
+//Wait开始后Add返回false，避免sync.WaitGroup的Add与Wait并发
+type HandlerGroup struct {
+	mu     sync.Mutex
+	wg     sync.WaitGroup
+	closed bool
+}
+
+//返回false时不应再启动handler
+func (g *HandlerGroup) Add() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return false
+	}
+	g.wg.Add(1)
+	return true
+}
+
+func (g *HandlerGroup) Done() {
+	g.wg.Done()
+}
+
+//不再接受新的handler，等待已有的handler退出，ctx到期时返回ctx.Err()
+//调用时ctx已经到期(Drain强制关闭了剩余的Session)，则最多再等handlerGracePeriod，
+//只有handler仍在运行时才返回ctx.Err()
+func (g *HandlerGroup) Wait(ctx context.Context) error {
+	g.mu.Lock()
+	g.closed = true
+	g.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+
+	if ctx.Err() != nil {
+		timer := time.NewTimer(handlerGracePeriod)
+		defer timer.Stop()
+		select {
+		case <-done:
+			return nil
+		case <-timer.C:
+			return ctx.Err()
+		}
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
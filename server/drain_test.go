@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDrain(t *testing.T) {
+	sm := NewSessionManager()
+	quit := sm.NewSessionWithOptions(newTestCodec(), SessionOptions{})
+	stay := sm.NewSessionWithOptions(newTestCodec(), SessionOptions{})
+	//quit在ctx到期前自行关闭，stay需要Drain强制关闭
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		quit.Close()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if n := sm.Drain(ctx, "bye"); n != 1 {
+		t.Fatalf("Drain force closed %d sessions, want 1\n", n)
+	}
+	if !stay.isClosed() || stay.CloseReason() != ServerStoppedError {
+		t.Fatalf("straggler closed = %v, reason %v\n", stay.isClosed(), stay.CloseReason())
+	}
+
+	//Drain开始后新建的Session直接关闭
+	late := sm.NewSessionWithOptions(newTestCodec(), SessionOptions{})
+	if !late.isClosed() || sm.Len() != 0 {
+		t.Fatalf("session created after Drain: closed = %v, Len = %d\n", late.isClosed(), sm.Len())
+	}
+}
+
+func TestCloseReturnValue(t *testing.T) {
+	session := NewSession(newTestCodec(), 0)
+	if err := session.Close(); err != SessionClosedError {
+		t.Fatalf("first Close = %v, want SessionClosedError\n", err)
+	}
+	if err := session.Close(); err != SessionClosedError {
+		t.Fatalf("second Close = %v, want SessionClosedError\n", err)
+	}
+}
+
+func TestHandlerGroup(t *testing.T) {
+	var g HandlerGroup
+	if !g.Add() {
+		t.Fatal("Add failed before Wait")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := g.Wait(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Wait with a running handler = %v\n", err)
+	}
+	if g.Add() {
+		t.Fatal("Add succeeded after Wait started")
+	}
+
+	g.Done()
+	if err := g.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait err:%v\n", err)
+	}
+}
+
+//ctx已经到期时仍给handler一段退出时间，只有handler没退出才返回错误
+func TestHandlerGroupExpiredContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var g HandlerGroup
+	g.Add()
+	time.AfterFunc(20*time.Millisecond, g.Done)
+	if err := g.Wait(ctx); err != nil {
+		t.Fatalf("Wait after handlers exited err:%v\n", err)
+	}
+
+	var stuck HandlerGroup
+	stuck.Add()
+	defer stuck.Done()
+	if err := stuck.Wait(ctx); err != context.Canceled {
+		t.Fatalf("Wait with a running handler err:%v, want context.Canceled\n", err)
+	}
+}
@@ -0,0 +1,226 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gary163/seals/protocol"
+)
+
+//多个Server共用一个SessionManager和Handler，如同时监听tcp,tls和websocket，
+//Channel和广播可以跨越所有的传输方式
+type Group struct {
+	sm        *SessionManager
+	handler   Handler
+	mu        sync.Mutex
+	listeners []*groupListener
+	stopOnce  sync.Once
+}
+
+type groupListener struct {
+	name    string
+	adapter string
+	server  Server
+	stats   listenerCounters
+}
+
+type listenerCounters struct {
+	accepted int64
+	active   int64
+	received int64
+	sent     int64
+	errors   int64
+}
+
+//一个listener的统计，计数从创建开始累计
+type ListenerStats struct {
+	Name     string
+	Adapter  string
+	Accepted int64 //累计接入的Session数
+	Active   int64 //当前的Session数
+	Received int64 //收到的消息数
+	Sent     int64 //成功发出的消息数
+	Errors   int64 //收发失败次数
+}
+
+func NewGroup(handler Handler) *Group {
+	return NewGroupWithManager(handler, NewSessionManager())
+}
+
+func NewGroupWithManager(handler Handler, sm *SessionManager) *Group {
+	return &Group{sm: sm, handler: handler}
+}
+
+func (g *Group) Manager() *SessionManager {
+	return g.sm
+}
+
+//添加一个listener，name用于区分统计，同一种adapter可以添加多次
+func (g *Group) Listen(name string, adapter string, config string, protocol protocol.Protocol) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, l := range g.listeners {
+		if l.name == name {
+			return fmt.Errorf("Group: listener %q already exists", name)
+		}
+	}
+
+	l := &groupListener{name: name, adapter: adapter}
+	server, err := NewServerWithManager(adapter, config, protocol, l.wrap(g.handler), g.sm)
+	if err != nil {
+		return err
+	}
+	l.server = server
+	g.listeners = append(g.listeners, l)
+	return nil
+}
+
+//按name取出listener的Server，不存在时返回nil
+func (g *Group) Server(name string) Server {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, l := range g.listeners {
+		if l.name == name {
+			return l.server
+		}
+	}
+	return nil
+}
+
+//统计接入的Session和收发的消息，再交给Group的Handler
+func (l *groupListener) wrap(handler Handler) Handler {
+	c := &l.stats
+	return HandlerFunc(func(session *Session) {
+		atomic.AddInt64(&c.accepted, 1)
+		atomic.AddInt64(&c.active, 1)
+		defer atomic.AddInt64(&c.active, -1)
+
+		session.Use(Interceptor{
+			Receive: func(session *Session, next ReceiveFunc) (interface{}, error) {
+				msg, err := next()
+				if err == nil {
+					atomic.AddInt64(&c.received, 1)
+				} else if err != io.EOF && err != SessionClosedError {
+					atomic.AddInt64(&c.errors, 1)
+				}
+				return msg, err
+			},
+			Send: func(session *Session, msg interface{}, next SendFunc) error {
+				err := next(msg)
+				if err == nil {
+					atomic.AddInt64(&c.sent, 1)
+				} else {
+					atomic.AddInt64(&c.errors, 1)
+				}
+				return err
+			},
+		})
+		handler.Handle(session)
+	})
+}
+
+//运行所有listener，直到全部退出；任何一个异常退出时停止整个Group并返回该错误
+func (g *Group) Run() error {
+	g.mu.Lock()
+	listeners := append([]*groupListener(nil), g.listeners...)
+	g.mu.Unlock()
+
+	errs := make(chan error, len(listeners))
+	for _, l := range listeners {
+		go func(l *groupListener) {
+			err := l.server.Run()
+			if err == io.EOF {
+				err = nil
+			}
+			if err != nil {
+				err = fmt.Errorf("Group: listener %q: %v", l.name, err)
+			}
+			errs <- err
+		}(l)
+	}
+
+	var first error
+	for range listeners {
+		if err := <-errs; err != nil && first == nil {
+			first = err
+			g.Stop()
+		}
+	}
+	return first
+}
+
+//停止所有listener并关闭所有Session
+func (g *Group) Stop() error {
+	g.stopOnce.Do(func() {
+		g.mu.Lock()
+		listeners := append([]*groupListener(nil), g.listeners...)
+		g.mu.Unlock()
+		for _, l := range listeners {
+			l.server.Stop()
+		}
+		g.sm.Destroy()
+	})
+	return nil
+}
+
+//支持优雅关闭的listener同时停止接入并Drain共享的Session，goingAway只发送一次，
+//不支持的listener在Drain结束后直接Stop，返回被强制关闭的Session数
+func (g *Group) StopGracefully(ctx context.Context, goingAway interface{}) (int, error) {
+	g.mu.Lock()
+	listeners := append([]*groupListener(nil), g.listeners...)
+	g.mu.Unlock()
+
+	type result struct {
+		n   int
+		err error
+	}
+	results := make(chan result, len(listeners))
+	running := 0
+	for _, l := range listeners {
+		gs, ok := l.server.(GracefulServer)
+		if !ok {
+			continue
+		}
+		gs.SetGoingAway(goingAway)
+		goingAway = nil
+		running++
+		go func() {
+			n, err := gs.StopGracefully(ctx)
+			results <- result{n, err}
+		}()
+	}
+
+	var n int
+	var err error
+	for i := 0; i < running; i++ {
+		r := <-results
+		n += r.n
+		if r.err != nil && err == nil {
+			err = r.err
+		}
+	}
+	g.Stop()
+	return n, err
+}
+
+func (g *Group) Stats() []ListenerStats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	stats := make([]ListenerStats, 0, len(g.listeners))
+	for _, l := range g.listeners {
+		c := &l.stats
+		stats = append(stats, ListenerStats{
+			Name:     l.name,
+			Adapter:  l.adapter,
+			Accepted: atomic.LoadInt64(&c.accepted),
+			Active:   atomic.LoadInt64(&c.active),
+			Received: atomic.LoadInt64(&c.received),
+			Sent:     atomic.LoadInt64(&c.sent),
+			Errors:   atomic.LoadInt64(&c.errors),
+		})
+	}
+	return stats
+}
@@ -0,0 +1,87 @@
+package server
+
+//SessionManager上的生命周期钩子，用于监控、在线状态、审计等，不需要改动业务handler
+//钩子在触发事件的goroutine中同步调用，不应阻塞
+type sessionHooks struct {
+	created      []func(*Session)
+	closed       []func(*Session, error)
+	receiveError []func(*Session, error)
+	sendError    []func(*Session, interface{}, error)
+	sendDropped  []func(*Session, interface{}, error)
+}
+
+//Session创建后调用
+func (sm *SessionManager) OnSessionCreated(hook func(session *Session)) {
+	sm.hooksMu.Lock()
+	defer sm.hooksMu.Unlock()
+	sm.hooks.created = append(sm.hooks.created, hook)
+}
+
+//Session关闭后调用，reason为关闭原因，主动Close时为nil
+func (sm *SessionManager) OnSessionClosed(hook func(session *Session, reason error)) {
+	sm.hooksMu.Lock()
+	defer sm.hooksMu.Unlock()
+	sm.hooks.closed = append(sm.hooks.closed, hook)
+}
+
+//从codec读取消息出错时调用，包括对端关闭时的io.EOF
+func (sm *SessionManager) OnReceiveError(hook func(session *Session, err error)) {
+	sm.hooksMu.Lock()
+	defer sm.hooksMu.Unlock()
+	sm.hooks.receiveError = append(sm.hooks.receiveError, hook)
+}
+
+//消息发送失败或因sendChan满被丢弃时调用
+func (sm *SessionManager) OnSendError(hook func(session *Session, msg interface{}, err error)) {
+	sm.hooksMu.Lock()
+	defer sm.hooksMu.Unlock()
+	sm.hooks.sendError = append(sm.hooks.sendError, hook)
+}
+
+//消息因sendChan满被丢弃时调用，err为SendTimeoutError、SendDroppedError或SessionBlockedError
+//对该SessionManager上的所有Session生效，可以据此统计或重发被丢弃的消息
+func (sm *SessionManager) OnSendDropped(hook func(session *Session, msg interface{}, err error)) {
+	sm.hooksMu.Lock()
+	defer sm.hooksMu.Unlock()
+	sm.hooks.sendDropped = append(sm.hooks.sendDropped, hook)
+}
+
+func (sm *SessionManager) sessionCreated(session *Session) {
+	sm.hooksMu.RLock()
+	defer sm.hooksMu.RUnlock()
+	for _, hook := range sm.hooks.created {
+		hook(session)
+	}
+}
+
+func (sm *SessionManager) sessionClosed(session *Session, reason error) {
+	sm.hooksMu.RLock()
+	defer sm.hooksMu.RUnlock()
+	for _, hook := range sm.hooks.closed {
+		hook(session, reason)
+	}
+}
+
+func (sm *SessionManager) receiveError(session *Session, err error) {
+	sm.hooksMu.RLock()
+	defer sm.hooksMu.RUnlock()
+	for _, hook := range sm.hooks.receiveError {
+		hook(session, err)
+	}
+}
+
+func (sm *SessionManager) sendError(session *Session, msg interface{}, err error) {
+	sm.hooksMu.RLock()
+	defer sm.hooksMu.RUnlock()
+	for _, hook := range sm.hooks.sendError {
+		hook(session, msg, err)
+	}
+}
+
+func (sm *SessionManager) sendDropped(session *Session, msg interface{}, err error) {
+	sm.hooksMu.RLock()
+	defer sm.hooksMu.RUnlock()
+	for _, hook := range sm.hooks.sendDropped {
+		hook(session, msg, err)
+	}
+}
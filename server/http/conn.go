@@ -0,0 +1,101 @@
+package httpserver
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/gary163/seals/protocol"
+)
+
+var errResponseSent = errors.New("httpServer:response already sent")
+
+//一次http请求适配成的连接：从请求body读取消息，Send写入的内容在handler返回后作为响应body发出
+type httpConn struct {
+	body     io.Reader //http.MaxBytesReader，超过limit时返回错误
+	limit    int64
+	read     int64
+	tooLarge bool
+	mu       sync.Mutex
+	resp     bytes.Buffer
+	closed   bool
+	local    net.Addr
+	remote   net.Addr
+}
+
+func (c *httpConn) Read(p []byte) (int, error) {
+	n, err := c.body.Read(p)
+	c.mu.Lock()
+	c.read += int64(n)
+	if err != nil && err != io.EOF && c.read >= c.limit {
+		c.tooLarge = true
+	}
+	c.mu.Unlock()
+	return n, err
+}
+
+//请求body是否超过了maxBodySize
+func (c *httpConn) bodyTooLarge() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tooLarge
+}
+
+func (c *httpConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return 0, errResponseSent
+	}
+	return c.resp.Write(p)
+}
+
+func (c *httpConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+//关闭连接并取出响应内容，之后的Write都会失败
+func (c *httpConn) response() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return c.resp.Bytes()
+}
+
+func (c *httpConn) LocalAddr() net.Addr {
+	return c.local
+}
+
+func (c *httpConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+//记录Receive遇到的第一个错误(EOF除外)，handler返回后据此回复400
+type recvCodec struct {
+	protocol.Codec
+	mu  sync.Mutex
+	err error
+}
+
+func (c *recvCodec) Receive() (interface{}, error) {
+	msg, err := c.Codec.Receive()
+	if err != nil && err != io.EOF {
+		c.mu.Lock()
+		if c.err == nil {
+			c.err = err
+		}
+		c.mu.Unlock()
+	}
+	return msg, err
+}
+
+func (c *recvCodec) recvErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
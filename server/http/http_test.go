@@ -0,0 +1,154 @@
+package httpserver
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/gary163/seals/protocol"
+	_ "github.com/gary163/seals/protocol/binary"
+	"github.com/gary163/seals/server"
+)
+
+type bufferConn struct {
+	bytes.Buffer
+}
+
+func (b *bufferConn) Close() error {
+	return nil
+}
+
+func TestHTTPEcho(t *testing.T) {
+	proto, err := protocol.NewProtocol("binary", `{"fixlen":{}}`)
+	if err != nil {
+		t.Fatalf("New protocol err:%v\n", err)
+	}
+
+	//和tcp长连接一样的handler：读到EOF为止，回显每条消息，"skip"不回复
+	srv, err := server.NewServer("httpServer", `{"addr":"127.0.0.1:0","path":"/rpc"}`, proto, server.HandlerFunc(func(session *server.Session) {
+		defer session.Close()
+		if r, ok := Request(session); !ok || r.Header.Get("X-Trace") != "t1" {
+			t.Errorf("request not attached to session\n")
+		}
+		for {
+			msg, err := session.Receive()
+			if err != nil {
+				return
+			}
+			if string(msg.([]byte)) != "skip" {
+				session.Send(msg)
+			}
+		}
+	}))
+	if err != nil {
+		t.Fatalf("New server err:%v\n", err)
+	}
+	go srv.Run()
+	defer srv.Stop()
+	url := "http://" + srv.(*httpServer).listener.Addr().String() + "/rpc"
+
+	client := &http.Client{Transport: &http.Transport{}}
+	post := func(msgs ...string) (*http.Response, [][]byte) {
+		var body bufferConn
+		codec, _ := proto.NewCodec(&body)
+		for _, msg := range msgs {
+			codec.Send([]byte(msg))
+		}
+		req, _ := http.NewRequest(http.MethodPost, url, &body)
+		req.Header.Set("X-Trace", "t1")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Post err:%v\n", err)
+		}
+		defer resp.Body.Close()
+		data, _ := ioutil.ReadAll(resp.Body)
+
+		var replies [][]byte
+		codec, _ = proto.NewCodec(&bufferConn{*bytes.NewBuffer(data)})
+		for {
+			msg, err := codec.Receive()
+			if err != nil {
+				break
+			}
+			replies = append(replies, msg.([]byte))
+		}
+		return resp, replies
+	}
+
+	resp, replies := post("hello", "world")
+	if resp.StatusCode != http.StatusOK || len(replies) != 2 || string(replies[0]) != "hello" || string(replies[1]) != "world" {
+		t.Fatalf("status %d replies %q\n", resp.StatusCode, replies)
+	}
+
+	resp, replies = post("skip")
+	if resp.StatusCode != http.StatusNoContent || len(replies) != 0 {
+		t.Fatalf("status %d replies %q, want 204\n", resp.StatusCode, replies)
+	}
+
+	resp, err = client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("GET status %d, want 405\n", resp.StatusCode)
+	}
+}
+
+//body解码失败回复400，超过maxBodySize回复413，handler已经Send的内容不会发出
+func TestHTTPBadBody(t *testing.T) {
+	proto, err := protocol.NewProtocol("binary", `{"fixlen":{}}`)
+	if err != nil {
+		t.Fatalf("New protocol err:%v\n", err)
+	}
+
+	srv, err := server.NewServer("httpServer", `{"addr":"127.0.0.1:0","path":"/rpc","maxBodySize":"64"}`, proto, server.HandlerFunc(func(session *server.Session) {
+		defer session.Close()
+		session.Send([]byte("partial"))
+		for {
+			if _, err := session.Receive(); err != nil {
+				return
+			}
+		}
+	}))
+	if err != nil {
+		t.Fatalf("New server err:%v\n", err)
+	}
+	go srv.Run()
+	defer srv.Stop()
+	url := "http://" + srv.(*httpServer).listener.Addr().String() + "/rpc"
+
+	frame := func(msg []byte) []byte {
+		var body bufferConn
+		codec, _ := proto.NewCodec(&body)
+		codec.Send(msg)
+		return body.Bytes()
+	}
+	client := &http.Client{Transport: &http.Transport{}}
+	truncated := frame([]byte("hello"))
+	truncated = truncated[:len(truncated)-2]
+	tests := []struct {
+		name   string
+		body   []byte
+		status int
+	}{
+		{"truncated", truncated, http.StatusBadRequest},
+		{"too large", frame(bytes.Repeat([]byte("x"), 100)), http.StatusRequestEntityTooLarge},
+		{"ok", frame([]byte("hello")), http.StatusOK},
+	}
+	for _, tt := range tests {
+		resp, err := client.Post(url, "application/octet-stream", bytes.NewReader(tt.body))
+		if err != nil {
+			t.Fatalf("%s: Post err:%v\n", tt.name, err)
+		}
+		data, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Fatalf("%s: status %d, want %d\n", tt.name, resp.StatusCode, tt.status)
+		}
+		if tt.status != http.StatusOK && bytes.Contains(data, []byte("partial")) {
+			t.Fatalf("%s: handler reply sent with an error status\n", tt.name)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package httpserver
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gary163/seals/protocol"
+	"github.com/gary163/seals/server"
+)
+
+const (
+	defaultMaxConn = 200000
+	defaultAddr = "0.0.0.0:0"
+	defaultPath = "/"
+	defaultHttpTimeout = 5
+	defaultMaxBodySize = 1<<20
+	defaultContentType = "application/octet-stream"
+)
+
+//短连接http服务，每个POST请求对应一个一次性的Session：
+//请求body用配置的protocol解码成消息交给handler，handler中Send的消息编码后作为响应body
+//handler返回时请求结束，同一个handler可以同时服务tcp长连接和http调用方
+type httpServer struct {
+	addr        string
+	maxConn     int//最大并发请求数
+	conns       int64//当前正在处理的请求数
+	paths       map[string]bool
+	maxBodySize int64
+	contentType string
+	listener    net.Listener
+	httpServer  *http.Server
+	protocol    protocol.Protocol
+	handler     server.Handler
+	sm          *server.SessionManager
+}
+
+type requestKey struct{}
+
+func init() {
+	server.RegisterServer("httpServer",&httpServer{})
+}
+
+func (s *httpServer) Init(config string, protocol protocol.Protocol, handler server.Handler, sm *server.SessionManager) error {
+	var cfg map[string]string
+	err := json.Unmarshal([]byte(config),&cfg)
+	if err != nil {
+		return err
+	}
+
+	if _,ok := cfg["maxConn"]; !ok {
+		cfg["maxConn"] = strconv.Itoa(defaultMaxConn)
+	}
+
+	if _,ok := cfg["addr"]; !ok {
+		cfg["addr"] = defaultAddr
+	}
+
+	if _,ok := cfg["path"]; !ok {
+		cfg["path"] = defaultPath
+	}
+
+	if _,ok := cfg["httpTimeout"]; !ok {
+		cfg["httpTimeout"] = strconv.Itoa(int(defaultHttpTimeout * time.Second))
+	}
+
+	if _,ok := cfg["maxBodySize"]; !ok {
+		cfg["maxBodySize"] = strconv.Itoa(defaultMaxBodySize)
+	}
+
+	if _,ok := cfg["contentType"]; !ok {
+		cfg["contentType"] = defaultContentType
+	}
+
+	//多个path用逗号分隔
+	s.paths = make(map[string]bool)
+	for _,path := range strings.Split(cfg["path"], ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			s.paths[path] = true
+		}
+	}
+
+	s.maxConn,_     = strconv.Atoi(cfg["maxConn"])
+	s.maxBodySize,_ = strconv.ParseInt(cfg["maxBodySize"], 10, 64)
+	s.addr          = cfg["addr"]
+	s.contentType   = cfg["contentType"]
+	httpTimeout,_   := strconv.Atoi(cfg["httpTimeout"])
+	s.protocol = protocol
+	s.handler  = handler
+	s.sm       = sm
+
+	tlsConfig,err := server.ParseServerTLSConfig(cfg)
+	if err != nil {
+		return err
+	}
+	//"reusePort":"true"时多个进程可以监听同一端口，热重启时使用父进程传下来的socket
+	reusePort,_ := strconv.ParseBool(cfg["reusePort"])
+	if s.listener,err = server.Listen("tcp", s.addr, reusePort); err != nil {
+		return err
+	}
+	if tlsConfig != nil {
+		s.listener = tls.NewListener(s.listener, tlsConfig)
+	}
+
+	s.httpServer = &http.Server{
+		Addr:         s.addr,
+		Handler:      s,
+		ReadTimeout:  time.Duration(httpTimeout),
+		WriteTimeout: time.Duration(httpTimeout),
+	}
+	return nil
+}
+
+func (s *httpServer) Run() error {
+	err := s.httpServer.Serve(s.listener)
+	if err == http.ErrServerClosed {
+		return io.EOF
+	}
+	return err
+}
+
+func (s *httpServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.paths[r.URL.Path] {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if atomic.AddInt64(&s.conns, 1) > int64(s.maxConn) {
+		atomic.AddInt64(&s.conns, -1)
+		log.Printf("Too manay connection:%d\n",s.maxConn)
+		http.Error(w, "too many connections", http.StatusServiceUnavailable)
+		return
+	}
+	defer atomic.AddInt64(&s.conns, -1)
+
+	conn := &httpConn{body: http.MaxBytesReader(w, r.Body, s.maxBodySize), limit: s.maxBodySize}
+	if addr,ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		conn.local = addr
+	}
+	if addr,err := net.ResolveTCPAddr("tcp", r.RemoteAddr); err == nil {
+		conn.remote = addr
+	}
+
+	//同步send，保证handler返回前Send的消息都已经写入响应
+	inner,_ := s.protocol.NewCodec(conn)
+	codec := &recvCodec{Codec: inner}
+	session := s.sm.NewSessionWithInfo(codec, server.SessionOptions{}, server.ConnInfo{
+		Transport:  "http",
+		LocalAddr:  conn.LocalAddr(),
+		RemoteAddr: conn.RemoteAddr(),
+		TLS:        r.TLS,
+		Attrs:      map[interface{}]interface{}{requestKey{}: r},
+	})
+	s.handler.Handle(session)
+	resp := conn.response()
+	session.Close()
+
+	//body超长或解码失败时丢弃handler的回复
+	if conn.bodyTooLarge() {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err := codec.recvErr(); err != nil {
+		http.Error(w, "bad request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(resp) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", s.contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(resp)))
+	w.Write(resp)
+}
+
+//httpServer创建的Session可以取到对应的请求，用于读取请求头等信息
+func Request(session *server.Session) (*http.Request, bool) {
+	value,_ := session.Attr(requestKey{})
+	r,ok := value.(*http.Request)
+	return r, ok
+}
+
+func (s *httpServer) Stop() error {
+	s.httpServer.Close()
+	s.sm.Destroy()
+	return nil
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/gary163/seals/protocol"
+)
+
+const minIdleCheckInterval = 10 * time.Millisecond
+
+//关闭原因，Session未关闭时返回nil
+func (s *Session) CloseReason() error {
+	select {
+	case <-s.closeChan:
+		return s.closeReason
+	default:
+		return nil
+	}
+}
+
+func (s *Session) receive() (interface{}, error) {
+	for {
+		msg, err := s.codec.Receive()
+		if err != nil {
+			if s.sm != nil {
+				s.sm.receiveError(s, err)
+			}
+			return nil, err
+		}
+		atomic.StoreInt64(&s.lastRead, time.Now().UnixNano())
+
+		//心跳消息由框架处理，不交给handler
+		switch msg.(type) {
+		case *protocol.Ping:
+			s.sendMessage(&protocol.Pong{})
+		case *protocol.Pong:
+		default:
+			return msg, nil
+		}
+	}
+}
+
+func (s *Session) send(msg interface{}) error {
+	if err := s.codec.Send(msg); err != nil {
+		if s.sm != nil {
+			s.sm.sendError(s, msg, err)
+		}
+		return err
+	}
+	atomic.StoreInt64(&s.lastWrite, time.Now().UnixNano())
+	return nil
+}
+
+//定时检查读写空闲，超时则关闭Session，写空闲时发送心跳
+func (s *Session) idleLoop(opts SessionOptions) {
+	interval := time.Duration(0)
+	for _, d := range []time.Duration{opts.ReadIdle, opts.WriteIdle, opts.Idle, opts.Heartbeat} {
+		if d > 0 && (interval == 0 || d < interval) {
+			interval = d
+		}
+	}
+	interval /= 2
+	if interval < minIdleCheckInterval {
+		interval = minIdleCheckInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.closeChan:
+			return
+		case now := <-ticker.C:
+			readIdle := now.Sub(time.Unix(0, atomic.LoadInt64(&s.lastRead)))
+			writeIdle := now.Sub(time.Unix(0, atomic.LoadInt64(&s.lastWrite)))
+
+			switch {
+			case opts.ReadIdle > 0 && readIdle > opts.ReadIdle:
+				s.CloseWithReason(ReadIdleTimeoutError)
+				return
+			case opts.WriteIdle > 0 && writeIdle > opts.WriteIdle:
+				s.CloseWithReason(WriteIdleTimeoutError)
+				return
+			case opts.Idle > 0 && readIdle > opts.Idle && writeIdle > opts.Idle:
+				s.CloseWithReason(IdleTimeoutError)
+				return
+			}
+
+			if opts.Heartbeat > 0 && writeIdle >= opts.Heartbeat {
+				s.sendMessage(&protocol.Ping{})
+			}
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package server
+
+type ReceiveFunc func() (interface{}, error)
+type SendFunc func(msg interface{}) error
+
+//包裹Session的Receive和Send，两者都可以为nil
+//拦截器可以检查、修改消息，返回错误拒绝消息，或者不调用next直接返回(短路)
+//先注册的拦截器在最外层；Call/Reply发出的消息会被包装成*protocol.Correlated
+type Interceptor struct {
+	Receive func(session *Session, next ReceiveFunc) (interface{}, error)
+	Send    func(session *Session, msg interface{}, next SendFunc) error
+}
+
+//为该SessionManager之后创建的所有Session添加拦截器
+func (sm *SessionManager) Use(interceptors ...Interceptor) {
+	sm.hooksMu.Lock()
+	defer sm.hooksMu.Unlock()
+	sm.interceptors = append(sm.interceptors, interceptors...)
+}
+
+func (sm *SessionManager) Interceptors() []Interceptor {
+	sm.hooksMu.RLock()
+	defer sm.hooksMu.RUnlock()
+	return append([]Interceptor(nil), sm.interceptors...)
+}
+
+//包装handler，在Handle之前给Session加上拦截器，可直接用于NewServer/NewClient
+func Intercept(handler Handler, interceptors ...Interceptor) Handler {
+	return HandlerFunc(func(session *Session) {
+		session.Use(interceptors...)
+		handler.Handle(session)
+	})
+}
+
+//给Session添加拦截器
+func (s *Session) Use(interceptors ...Interceptor) {
+	if len(interceptors) == 0 {
+		return
+	}
+	s.interceptMu.Lock()
+	defer s.interceptMu.Unlock()
+	s.interceptors = append(s.interceptors, interceptors...)
+}
+
+func (s *Session) Receive() (interface{}, error) {
+	s.interceptMu.RLock()
+	interceptors := s.interceptors
+	s.interceptMu.RUnlock()
+
+	next := ReceiveFunc(s.receiveMessage)
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		if receive := interceptors[i].Receive; receive != nil {
+			inner := next
+			next = func() (interface{}, error) {
+				return receive(s, inner)
+			}
+		}
+	}
+	return next()
+}
+
+func (s *Session) Send(msg interface{}) error {
+	s.interceptMu.RLock()
+	interceptors := s.interceptors
+	s.interceptMu.RUnlock()
+
+	next := SendFunc(s.sendMessage)
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		if send := interceptors[i].Send; send != nil {
+			inner := next
+			next = func(msg interface{}) error {
+				return send(s, msg, inner)
+			}
+		}
+	}
+	return next(msg)
+}
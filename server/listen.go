@@ -0,0 +1,170 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+//子进程通过该环境变量得知继承的监听socket，格式为"tcp:0.0.0.0:5000=3,tcp:0.0.0.0:5001=4"
+const ListenFdsEnv = "SEALS_LISTEN_FDS"
+
+var (
+	listenMu      sync.Mutex
+	inherited     map[string]*os.File//父进程传下来的、还没有被使用的socket
+	inheritParsed bool
+	active        = make(map[string]*trackedListener)//当前进程在监听的socket，Restart时交给子进程
+)
+
+type trackedListener struct {
+	net.Listener
+	key  string
+	file func() (*os.File, error)
+}
+
+func (l *trackedListener) Close() error {
+	listenMu.Lock()
+	if active[l.key] == l {
+		delete(active, l.key)
+	}
+	listenMu.Unlock()
+	return l.Listener.Close()
+}
+
+//监听network上的addr，有父进程传下来的同一地址的socket时直接使用，实现不断线的重启
+//reusePort为true时设置SO_REUSEPORT，多个进程可以同时监听同一端口，由内核分配连接
+func Listen(network, addr string, reusePort bool) (net.Listener, error) {
+	key := network + ":" + addr
+	listenMu.Lock()
+	defer listenMu.Unlock()
+	parseInherited()
+
+	var l net.Listener
+	var err error
+	if f, ok := inherited[key]; ok {
+		delete(inherited, key)
+		l, err = net.FileListener(f)
+		f.Close()
+	} else {
+		lc := net.ListenConfig{}
+		if reusePort {
+			lc.Control = setReusePort
+		}
+		l, err = lc.Listen(context.Background(), network, addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tracked := &trackedListener{Listener: l, key: key}
+	switch ln := l.(type) {
+	case *net.TCPListener:
+		tracked.file = ln.File
+	case *net.UnixListener:
+		tracked.file = ln.File
+	}
+	active[key] = tracked
+	return tracked, nil
+}
+
+func parseInherited() {
+	if inheritParsed {
+		return
+	}
+	inheritParsed = true
+	inherited = make(map[string]*os.File)
+	v := os.Getenv(ListenFdsEnv)
+	if v == "" {
+		return
+	}
+	for _, item := range strings.Split(v, ",") {
+		i := strings.LastIndex(item, "=")
+		if i < 0 {
+			continue
+		}
+		fd, err := strconv.Atoi(item[i+1:])
+		if err != nil {
+			continue
+		}
+		inherited[item[:i]] = os.NewFile(uintptr(fd), item[:i])
+	}
+	//不再传给之后由本进程创建的子进程
+	os.Unsetenv(ListenFdsEnv)
+}
+
+//用相同的参数启动新的进程，并把所有通过Listen创建的socket交给它
+//新进程启动后调用方应停止接入并优雅关闭已有的Session(如Group.StopGracefully)，之后退出
+func Restart() (*os.Process, error) {
+	env, files, err := listenerFiles()
+	if err != nil {
+		return nil, err
+	}
+	//exec取Fd时会把socket设为阻塞模式，而复制出的fd与本进程的listener共用这个状态，
+	//不改回非阻塞的话本进程的Accept会阻塞在系统调用里，Close也无法唤醒
+	defer func() {
+		for _, f := range files {
+			setNonblock(f.Fd())
+			f.Close()
+		}
+	}()
+
+	path, err := exec.LookPath(os.Args[0])
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(path, os.Args[1:]...)
+	cmd.Env = append(os.Environ(), env)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = files
+	if err = cmd.Start(); err != nil {
+		return nil, err
+	}
+	return cmd.Process, nil
+}
+
+//复制监听socket的fd，子进程中从3开始编号
+func listenerFiles() (string, []*os.File, error) {
+	listenMu.Lock()
+	defer listenMu.Unlock()
+
+	var items []string
+	var files []*os.File
+	for key, l := range active {
+		if l.file == nil {
+			continue
+		}
+		f, err := l.file()
+		if err != nil {
+			for _, f := range files {
+				f.Close()
+			}
+			return "", nil, fmt.Errorf("Restart: dup listener %s: %v", key, err)
+		}
+		items = append(items, key+"="+strconv.Itoa(3+len(files)))
+		files = append(files, f)
+	}
+	if len(files) == 0 {
+		return "", nil, errors.New("Restart: no listener to pass")
+	}
+	return ListenFdsEnv + "=" + strings.Join(items, ","), files, nil
+}
+
+func setReusePort(network, address string, c syscall.RawConn) error {
+	var sockErr error
+	err := c.Control(func(fd uintptr) {
+		sockErr = reusePort(fd)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
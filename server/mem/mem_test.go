@@ -0,0 +1,88 @@
+package memserver
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/gary163/seals/protocol"
+	_ "github.com/gary163/seals/protocol/binary"
+	"github.com/gary163/seals/server"
+)
+
+func TestMemEcho(t *testing.T) {
+	proto, err := protocol.NewProtocol("binary", `{"fixlen":{}}`)
+	if err != nil {
+		t.Fatalf("New protocol err:%v\n", err)
+	}
+
+	echo := server.HandlerFunc(func(session *server.Session) {
+		defer session.Close()
+		for {
+			msg, err := session.Receive()
+			if err != nil {
+				return
+			}
+			session.Send(msg)
+		}
+	})
+	srv, err := server.NewServer("memServer", `{"name":"echo"}`, proto, echo)
+	if err != nil {
+		t.Fatalf("New server err:%v\n", err)
+	}
+	go srv.Run()
+	defer srv.Stop()
+
+	if _, err := listen("echo"); err == nil {
+		t.Fatal("listen on a name in use succeeded")
+	}
+
+	//缓冲区比消息小，写入需要等待对端读取
+	cli, err := server.NewClient("memClient", `{"name":"echo","connNum":"5","bufferSize":"64"}`, proto, server.HandlerFunc(func(session *server.Session) {
+		defer session.Close()
+		for i := 0; i < 50; i++ {
+			msg := make([]byte, rand.Intn(1000)+1)
+			rand.Read(msg)
+			if err := session.Send(msg); err != nil {
+				t.Errorf("Send err:%v\n", err)
+				return
+			}
+			recv, err := session.Receive()
+			if err != nil {
+				t.Errorf("Receive err:%v\n", err)
+				return
+			}
+			if !bytes.Equal(msg, recv.([]byte)) {
+				t.Errorf("recv msg not equal send msg\n")
+			}
+		}
+	}))
+	if err != nil {
+		t.Fatalf("New client err:%v\n", err)
+	}
+	cli.Run()
+
+	//往返时间至少是两倍的单向延迟
+	var rtt time.Duration
+	cli, err = server.NewClient("memClient", `{"name":"echo","latency":"30ms"}`, proto, server.HandlerFunc(func(session *server.Session) {
+		defer session.Close()
+		start := time.Now()
+		session.Send([]byte("ping"))
+		if _, err := session.Receive(); err != nil {
+			t.Errorf("Receive err:%v\n", err)
+		}
+		rtt = time.Since(start)
+	}))
+	if err != nil {
+		t.Fatalf("New client err:%v\n", err)
+	}
+	cli.Run()
+	if rtt < 60*time.Millisecond {
+		t.Fatalf("rtt %v, want at least 60ms\n", rtt)
+	}
+
+	if _, err := dial("missing", defaultBufferSize, 0); err != errListenerNotFound {
+		t.Fatalf("dial missing name err:%v\n", err)
+	}
+}
@@ -0,0 +1,88 @@
+package memserver
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gary163/seals/protocol"
+	"github.com/gary163/seals/server"
+)
+
+const defaultConnNum = 1
+
+//bufferSize和latency作用于客户端建立的连接的两个方向
+type memClient struct {
+	name        string
+	connNum     int
+	bufferSize  int
+	latency     time.Duration
+	sessionOpts server.SessionOptions
+	handler     server.Handler
+	protocol    protocol.Protocol
+	sm          *server.SessionManager
+	wg          sync.WaitGroup
+}
+
+func (c *memClient) Init(config string, protocol protocol.Protocol, handler server.Handler, sm *server.SessionManager) error {
+	var cfg map[string]string
+	json.Unmarshal([]byte(config),&cfg)
+
+	if _,ok := cfg["name"]; !ok {
+		return errors.New("MemClient:Missing name parameter")
+	}
+	if _,ok := cfg["sendChanSize"]; !ok {
+		cfg["sendChanSize"] = strconv.Itoa(defaultSendChanSize)
+	}
+	if _,ok := cfg["connNum"]; !ok {
+		cfg["connNum"] = strconv.Itoa(defaultConnNum)
+	}
+
+	c.name      = cfg["name"]
+	c.connNum,_ = strconv.Atoi(cfg["connNum"])
+	var err error
+	if c.bufferSize,c.latency,err = parsePipeConfig(cfg); err != nil {
+		return err
+	}
+	if c.sessionOpts,err = server.ParseSessionOptions(cfg); err != nil {
+		return err
+	}
+	c.handler  = handler
+	c.protocol = protocol
+	c.sm       = sm
+	return nil
+}
+
+func (c *memClient) Run() {
+	for i:=0; i<c.connNum; i++ {
+		c.wg.Add(1)
+		go func(){
+			defer c.wg.Done()
+			conn,err := dial(c.name, c.bufferSize, c.latency)
+			if err != nil {
+				log.Printf("Client dial got a err:%v\n",err)
+				return
+			}
+			codec,_ := c.protocol.NewCodec(conn)
+			session := c.sm.NewSessionWithInfo(codec, c.sessionOpts, server.ConnInfo{
+				Transport:  "mem",
+				LocalAddr:  conn.LocalAddr(),
+				RemoteAddr: conn.RemoteAddr(),
+			})
+			c.handler.Handle(session)
+		}()
+	}
+	c.wg.Wait()
+}
+
+func (c *memClient) Close() error {
+	c.sm.Destroy()
+	return nil
+}
+
+func init(){
+	server.RegisterClient("memClient", &memClient{})
+}
@@ -0,0 +1,178 @@
+package memserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gary163/seals/protocol"
+	"github.com/gary163/seals/server"
+)
+
+const (
+	defaultMaxConn = 200000
+	defaultSendChanSize = 1024
+	defaultBufferSize = 64*1024
+	defaultAcceptBacklog = 128
+)
+
+var (
+	listeners   = make(map[string]*memListener)
+	listenersMu sync.Mutex
+)
+
+//按name注册在进程内，memClient通过name连接
+type memListener struct {
+	name      string
+	accept    chan *memConn
+	closeChan chan struct{}
+	closeOnce sync.Once
+}
+
+func listen(name string) (*memListener, error) {
+	listenersMu.Lock()
+	defer listenersMu.Unlock()
+	if _,ok := listeners[name]; ok {
+		return nil, fmt.Errorf("memServer:name %q is in use", name)
+	}
+	l := &memListener{
+		name:      name,
+		accept:    make(chan *memConn, defaultAcceptBacklog),
+		closeChan: make(chan struct{}),
+	}
+	listeners[name] = l
+	return l, nil
+}
+
+func (l *memListener) close() {
+	l.closeOnce.Do(func() {
+		listenersMu.Lock()
+		if listeners[l.name] == l {
+			delete(listeners, l.name)
+		}
+		listenersMu.Unlock()
+		close(l.closeChan)
+	})
+}
+
+func dial(name string, bufferSize int, latency time.Duration) (*memConn, error) {
+	listenersMu.Lock()
+	l,ok := listeners[name]
+	listenersMu.Unlock()
+	if !ok {
+		return nil, errListenerNotFound
+	}
+
+	client,serverSide := newMemPair(name, bufferSize, latency)
+	select {
+	case l.accept <- serverSide:
+		return client, nil
+	case <-l.closeChan:
+		return nil, errListenerNotFound
+	}
+}
+
+//从配置中解析管道的缓冲区大小(字节)和单向延迟
+func parsePipeConfig(cfg map[string]string) (int, time.Duration, error) {
+	bufferSize := defaultBufferSize
+	var latency time.Duration
+	var err error
+	if v,ok := cfg["bufferSize"]; ok {
+		if bufferSize,err = strconv.Atoi(v); err != nil || bufferSize <= 0 {
+			return 0, 0, fmt.Errorf("mem:bufferSize %q is invalid", v)
+		}
+	}
+	if v,ok := cfg["latency"]; ok {
+		if latency,err = time.ParseDuration(v); err != nil {
+			return 0, 0, fmt.Errorf("mem:latency %q is invalid", v)
+		}
+	}
+	return bufferSize, latency, nil
+}
+
+//进程内的Server，不占用端口，用于单元测试和同一进程内的组件之间通信
+type memServer struct {
+	name        string
+	maxConn     int//最大连接数
+	listener    *memListener
+	sessionOpts server.SessionOptions
+	protocol    protocol.Protocol
+	handler     server.Handler
+	sm          *server.SessionManager
+}
+
+func init() {
+	server.RegisterServer("memServer",&memServer{})
+}
+
+func (s *memServer) Init(config string, protocol protocol.Protocol, handler server.Handler, sm *server.SessionManager) error {
+	var cfg map[string]string
+	err := json.Unmarshal([]byte(config),&cfg)
+	if err != nil {
+		return err
+	}
+
+	if _,ok := cfg["name"]; !ok {
+		return errors.New("MemServer:Missing name parameter")
+	}
+
+	if _,ok := cfg["maxConn"]; !ok {
+		cfg["maxConn"] = strconv.Itoa(defaultMaxConn)
+	}
+
+	if _,ok := cfg["sendChanSize"]; !ok {
+		cfg["sendChanSize"] = strconv.Itoa(defaultSendChanSize)
+	}
+
+	s.name      = cfg["name"]
+	s.maxConn,_ = strconv.Atoi(cfg["maxConn"])
+	if s.sessionOpts,err = server.ParseSessionOptions(cfg); err != nil {
+		return err
+	}
+	s.protocol = protocol
+	s.handler  = handler
+	s.sm       = sm
+
+	if s.listener,err = listen(s.name); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *memServer) Run() error {
+	for{
+		var conn *memConn
+		select {
+		case conn = <-s.listener.accept:
+		case <-s.listener.closeChan:
+			return io.EOF
+		}
+
+		if s.sm.Len() > int64(s.maxConn) {
+			log.Printf("Too manay connection:%d\n",s.maxConn)
+			conn.Close()
+			continue
+		}
+
+		go func(){
+			codec,_ := s.protocol.NewCodec(conn)
+			session := s.sm.NewSessionWithInfo(codec, s.sessionOpts, server.ConnInfo{
+				Transport:  "mem",
+				LocalAddr:  conn.LocalAddr(),
+				RemoteAddr: conn.RemoteAddr(),
+			})
+			s.handler.Handle(session)
+		}()
+	}
+}
+
+func (s *memServer) Stop() error {
+	s.listener.close()
+	s.sm.Destroy()
+	return nil
+}
@@ -0,0 +1,163 @@
+package memserver
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+var errListenerNotFound = errors.New("memServer: no server listening on this name")
+
+type chunk struct {
+	data []byte
+	at   time.Time //可以被读取的时间
+}
+
+//单向的内存管道，缓冲区满时Write阻塞，每次写入的数据经过latency之后才能被读到
+type pipe struct {
+	mu       sync.Mutex
+	chunks   []chunk
+	size     int
+	capacity int
+	latency  time.Duration
+	closed   bool
+
+	readable chan struct{}
+	writable chan struct{}
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+func newPipe(capacity int, latency time.Duration) *pipe {
+	p := &pipe{}
+	p.capacity = capacity
+	p.latency = latency
+	p.readable = make(chan struct{}, 1)
+	p.writable = make(chan struct{}, 1)
+	p.done = make(chan struct{})
+	return p
+}
+
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+func (p *pipe) Read(b []byte) (int, error) {
+	for {
+		p.mu.Lock()
+		if len(p.chunks) > 0 {
+			c := &p.chunks[0]
+			if wait := time.Until(c.at); wait > 0 {
+				p.mu.Unlock()
+				time.Sleep(wait)
+				continue
+			}
+			n := copy(b, c.data)
+			c.data = c.data[n:]
+			if len(c.data) == 0 {
+				p.chunks = p.chunks[1:]
+			}
+			p.size -= n
+			p.mu.Unlock()
+			notify(p.writable)
+			return n, nil
+		}
+		closed := p.closed
+		p.mu.Unlock()
+		//写端关闭后读完剩余数据再返回EOF
+		if closed {
+			return 0, io.EOF
+		}
+
+		select {
+		case <-p.readable:
+		case <-p.done:
+		}
+	}
+}
+
+func (p *pipe) Write(b []byte) (int, error) {
+	n := 0
+	for n < len(b) {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return n, io.ErrClosedPipe
+		}
+		if room := p.capacity - p.size; room > 0 {
+			if room > len(b)-n {
+				room = len(b) - n
+			}
+			data := append([]byte(nil), b[n:n+room]...)
+			p.chunks = append(p.chunks, chunk{data: data, at: time.Now().Add(p.latency)})
+			p.size += room
+			n += room
+			p.mu.Unlock()
+			notify(p.readable)
+			continue
+		}
+		p.mu.Unlock()
+
+		select {
+		case <-p.writable:
+		case <-p.done:
+		}
+	}
+	return n, nil
+}
+
+func (p *pipe) close() {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	p.doneOnce.Do(func() { close(p.done) })
+}
+
+type memAddr string
+
+func (a memAddr) Network() string { return "mem" }
+func (a memAddr) String() string  { return string(a) }
+
+//一端的连接，读对端写入的管道，写自己发出的管道
+type memConn struct {
+	r      *pipe
+	w      *pipe
+	local  memAddr
+	remote memAddr
+}
+
+func newMemPair(name string, capacity int, latency time.Duration) (*memConn, *memConn) {
+	c2s := newPipe(capacity, latency)
+	s2c := newPipe(capacity, latency)
+	client := &memConn{r: s2c, w: c2s, local: memAddr(name + "-client"), remote: memAddr(name)}
+	server := &memConn{r: c2s, w: s2c, local: memAddr(name), remote: memAddr(name + "-client")}
+	return client, server
+}
+
+func (c *memConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+func (c *memConn) Write(b []byte) (int, error) {
+	return c.w.Write(b)
+}
+
+//关闭后两个方向都不能再写，对端读完已发出的数据后得到EOF
+func (c *memConn) Close() error {
+	c.w.close()
+	c.r.close()
+	return nil
+}
+
+func (c *memConn) LocalAddr() net.Addr {
+	return c.local
+}
+
+func (c *memConn) RemoteAddr() net.Addr {
+	return c.remote
+}
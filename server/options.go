@@ -0,0 +1,61 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+//Session的可选配置，由各个Server/Client从配置中解析后传给NewSessionWithOptions
+type SessionOptions struct {
+	SendChanSize int           //异步send的buffer个数，0表示同步send
+	SendPolicy   SendPolicy    //sendChan满时的处理策略
+	SendTimeout  time.Duration //SendPolicyBlock时最长的阻塞时间，0表示一直阻塞直到Session关闭
+	ReadIdle     time.Duration //超过该时间没有收到消息则关闭Session
+	WriteIdle    time.Duration //超过该时间没有成功发出消息则关闭Session
+	Idle         time.Duration //读写都空闲超过该时间则关闭Session
+	Heartbeat    time.Duration //写空闲超过该时间时发送心跳Ping，对端自动回Pong
+	//消息因sendChan满被丢弃时的回调，不能从配置中解析
+	//Server创建的Session可以用SessionManager.OnSendDropped得到通知
+	DropCallback func(session *Session, msg interface{})
+}
+
+//从server/client的配置中解析Session相关的配置项，时间格式为time.ParseDuration支持的格式，如"30s"
+//sendChanSize,sendChanPolicy,sendChanTimeout,readIdleTimeout,writeIdleTimeout,idleTimeout,heartbeat
+func ParseSessionOptions(cfg map[string]string) (SessionOptions, error) {
+	var opts SessionOptions
+	var err error
+
+	if v, ok := cfg["sendChanSize"]; ok {
+		if opts.SendChanSize, err = strconv.Atoi(v); err != nil {
+			return opts, fmt.Errorf("Session:sendChanSize %q is invalid", v)
+		}
+	}
+
+	if v, ok := cfg["sendChanPolicy"]; ok {
+		if opts.SendPolicy, err = ParseSendPolicy(v); err != nil {
+			return opts, err
+		}
+	}
+
+	durations := []struct {
+		key string
+		val *time.Duration
+	}{
+		{"sendChanTimeout", &opts.SendTimeout},
+		{"readIdleTimeout", &opts.ReadIdle},
+		{"writeIdleTimeout", &opts.WriteIdle},
+		{"idleTimeout", &opts.Idle},
+		{"heartbeat", &opts.Heartbeat},
+	}
+	for _, d := range durations {
+		v, ok := cfg[d.key]
+		if !ok || v == "" {
+			continue
+		}
+		if *d.val, err = time.ParseDuration(v); err != nil {
+			return opts, fmt.Errorf("Session:%s %q is invalid", d.key, v)
+		}
+	}
+	return opts, nil
+}
@@ -0,0 +1,161 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidHeader = errors.New("proxyproto: invalid PROXY header")
+	ErrUnsupported   = errors.New("proxyproto: unsupported PROXY header")
+)
+
+const maxV1Length = 107
+
+var v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+//解析到的地址，LOCAL命令或UNKNOWN协议时src和dst为nil，使用连接本身的地址
+type header struct {
+	src net.Addr
+	dst net.Addr
+}
+
+//从r中读取PROXY头，没有头时返回nil且不消耗任何数据
+func readHeader(r *bufio.Reader) (*header, error) {
+	b, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	switch b[0] {
+	case 'P':
+		if !hasPrefix(r, []byte("PROXY ")) {
+			return nil, nil
+		}
+		return readV1(r)
+	case '\r':
+		if !hasPrefix(r, v2Signature) {
+			return nil, nil
+		}
+		return readV2(r)
+	}
+	return nil, nil
+}
+
+//逐字节比较，数据不够时才继续等待，避免没有头的短消息被阻塞
+func hasPrefix(r *bufio.Reader, prefix []byte) bool {
+	for i := 1; i <= len(prefix); i++ {
+		b, err := r.Peek(i)
+		if err != nil || b[i-1] != prefix[i-1] {
+			return false
+		}
+	}
+	return true
+}
+
+//PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n
+func readV1(r *bufio.Reader) (*header, error) {
+	var line []byte
+	for len(line) < maxV1Length {
+		c, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, c)
+		if c == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, ErrInvalidHeader
+	}
+
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return &header{}, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, ErrInvalidHeader
+	}
+	srcIP, dstIP := net.ParseIP(fields[2]), net.ParseIP(fields[3])
+	srcPort, err1 := strconv.ParseUint(fields[4], 10, 16)
+	dstPort, err2 := strconv.ParseUint(fields[5], 10, 16)
+	if srcIP == nil || dstIP == nil || err1 != nil || err2 != nil {
+		return nil, ErrInvalidHeader
+	}
+	return &header{
+		src: &net.TCPAddr{IP: srcIP, Port: int(srcPort)},
+		dst: &net.TCPAddr{IP: dstIP, Port: int(dstPort)},
+	}, nil
+}
+
+//12字节签名，版本和命令，地址族和协议，2字节长度，之后是地址和TLV
+func readV2(r *bufio.Reader) (*header, error) {
+	var fixed [16]byte
+	if _, err := readFull(r, fixed[:]); err != nil {
+		return nil, err
+	}
+	verCmd, family := fixed[12], fixed[13]
+	length := int(binary.BigEndian.Uint16(fixed[14:16]))
+	if verCmd>>4 != 2 {
+		return nil, ErrInvalidHeader
+	}
+	body := make([]byte, length)
+	if _, err := readFull(r, body); err != nil {
+		return nil, err
+	}
+
+	switch verCmd & 0xf {
+	case 0: //LOCAL，负载均衡器自己的健康检查等
+		return &header{}, nil
+	case 1: //PROXY
+	default:
+		return nil, ErrUnsupported
+	}
+
+	var ipLen int
+	switch family >> 4 {
+	case 0: //AF_UNSPEC
+		return &header{}, nil
+	case 1:
+		ipLen = net.IPv4len
+	case 2:
+		ipLen = net.IPv6len
+	default:
+		return nil, ErrUnsupported
+	}
+	if len(body) < 2*ipLen+4 {
+		return nil, ErrInvalidHeader
+	}
+	srcIP := net.IP(append([]byte(nil), body[:ipLen]...))
+	dstIP := net.IP(append([]byte(nil), body[ipLen:2*ipLen]...))
+	srcPort := int(binary.BigEndian.Uint16(body[2*ipLen:]))
+	dstPort := int(binary.BigEndian.Uint16(body[2*ipLen+2:]))
+
+	if family&0xf == 2 { //DGRAM
+		return &header{
+			src: &net.UDPAddr{IP: srcIP, Port: srcPort},
+			dst: &net.UDPAddr{IP: dstIP, Port: dstPort},
+		}, nil
+	}
+	return &header{
+		src: &net.TCPAddr{IP: srcIP, Port: srcPort},
+		dst: &net.TCPAddr{IP: dstIP, Port: dstPort},
+	}, nil
+}
+
+func readFull(r *bufio.Reader, b []byte) (int, error) {
+	n := 0
+	for n < len(b) {
+		m, err := r.Read(b[n:])
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,154 @@
+package proxyproto
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultHeaderTimeout = 5 * time.Second
+
+//PROXY协议的配置，可以直接从Server的配置中解析：
+//proxyProtocol 为true时启用，支持v1和v2
+//proxyTrusted 逗号分隔的IP或CIDR，只解析来自这些地址的PROXY头，启用时必填
+//其他来源的连接不解析PROXY头，它们发来的头不能改变连接的地址
+//proxyHeaderTimeout 等待PROXY头的最长时间，默认5s
+type Config struct {
+	Trusted       []*net.IPNet
+	HeaderTimeout time.Duration
+}
+
+//没有启用时返回nil
+func ParseConfig(cfg map[string]string) (*Config, error) {
+	if enable, _ := strconv.ParseBool(cfg["proxyProtocol"]); !enable {
+		return nil, nil
+	}
+
+	c := &Config{HeaderTimeout: defaultHeaderTimeout}
+	if v, ok := cfg["proxyHeaderTimeout"]; ok {
+		var err error
+		if c.HeaderTimeout, err = time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("proxyproto:proxyHeaderTimeout %q is invalid", v)
+		}
+	}
+	for _, s := range strings.Split(cfg["proxyTrusted"], ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			if ip := net.ParseIP(s); ip != nil && ip.To4() != nil {
+				s += "/32"
+			} else {
+				s += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("proxyproto:proxyTrusted %q is invalid", s)
+		}
+		c.Trusted = append(c.Trusted, ipNet)
+	}
+	//信任所有来源时任何客户端都能伪造自己的地址
+	if len(c.Trusted) == 0 {
+		return nil, errors.New("proxyproto:proxyTrusted is required when proxyProtocol is enabled")
+	}
+	return c, nil
+}
+
+//Trusted为空时不信任任何来源
+func (c *Config) trusted(addr net.Addr) bool {
+	var ip net.IP
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		ip = a.IP
+	case *net.UDPAddr:
+		ip = a.IP
+	default:
+		return false
+	}
+	for _, ipNet := range c.Trusted {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+//只有来自可信地址的连接才会被包装成*Conn
+type Listener struct {
+	net.Listener
+	config *Config
+}
+
+func NewListener(l net.Listener, config *Config) *Listener {
+	return &Listener{Listener: l, config: config}
+}
+
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if !l.config.trusted(conn.RemoteAddr()) {
+		return conn, nil
+	}
+	return NewConn(conn, l.config.HeaderTimeout), nil
+}
+
+//第一次Read或获取地址时才读取PROXY头，不会阻塞Accept
+//有头时RemoteAddr和LocalAddr返回真实的客户端和目的地址
+type Conn struct {
+	net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+	once    sync.Once
+	header  *header
+	err     error
+}
+
+func NewConn(conn net.Conn, timeout time.Duration) *Conn {
+	return &Conn{Conn: conn, reader: bufio.NewReader(conn), timeout: timeout}
+}
+
+//读取PROXY头，没有头不算错误，结果只解析一次
+func (c *Conn) Parse() error {
+	c.once.Do(func() {
+		if c.timeout > 0 {
+			c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+			defer c.Conn.SetReadDeadline(time.Time{})
+		}
+		c.header, c.err = readHeader(c.reader)
+	})
+	return c.err
+}
+
+func (c *Conn) Read(b []byte) (int, error) {
+	if err := c.Parse(); err != nil {
+		return 0, err
+	}
+	return c.reader.Read(b)
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	if c.Parse() == nil && c.header != nil && c.header.src != nil {
+		return c.header.src
+	}
+	return c.Conn.RemoteAddr()
+}
+
+func (c *Conn) LocalAddr() net.Addr {
+	if c.Parse() == nil && c.header != nil && c.header.dst != nil {
+		return c.header.dst
+	}
+	return c.Conn.LocalAddr()
+}
+
+//发送PROXY头的一端(通常是负载均衡器)的地址
+func (c *Conn) ProxyAddr() net.Addr {
+	return c.Conn.RemoteAddr()
+}
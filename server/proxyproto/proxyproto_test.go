@@ -0,0 +1,104 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+)
+
+func v2Header(cmd byte, src, dst *net.TCPAddr) []byte {
+	var body []byte
+	family := byte(0x11)
+	body = append(body, src.IP.To4()...)
+	body = append(body, dst.IP.To4()...)
+	var ports [4]byte
+	binary.BigEndian.PutUint16(ports[0:], uint16(src.Port))
+	binary.BigEndian.PutUint16(ports[2:], uint16(dst.Port))
+	body = append(body, ports[:]...)
+	body = append(body, 0x04, 0, 1, 'x') //TLV应被忽略
+
+	var b bytes.Buffer
+	b.Write(v2Signature)
+	b.WriteByte(0x20 | cmd)
+	b.WriteByte(family)
+	binary.Write(&b, binary.BigEndian, uint16(len(body)))
+	b.Write(body)
+	return b.Bytes()
+}
+
+func TestReadHeader(t *testing.T) {
+	src := &net.TCPAddr{IP: net.IPv4(203, 0, 113, 7), Port: 40000}
+	dst := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 443}
+
+	cases := []struct {
+		name    string
+		input   string
+		src     string
+		rest    string
+		wantErr bool
+	}{
+		{"v1 tcp4", "PROXY TCP4 203.0.113.7 10.0.0.1 40000 443\r\npayload", "203.0.113.7:40000", "payload", false},
+		{"v1 tcp6", "PROXY TCP6 2001:db8::1 2001:db8::2 40000 443\r\npayload", "[2001:db8::1]:40000", "payload", false},
+		{"v1 unknown", "PROXY UNKNOWN\r\npayload", "", "payload", false},
+		{"v1 bad port", "PROXY TCP4 203.0.113.7 10.0.0.1 x 443\r\npayload", "", "", true},
+		{"v2 proxy", string(v2Header(1, src, dst)) + "payload", "203.0.113.7:40000", "payload", false},
+		{"v2 local", string(v2Header(0, src, dst)) + "payload", "", "payload", false},
+		{"no header", "payload", "", "payload", false},
+		{"looks like header", "PROXIMITYpayload", "", "PROXIMITYpayload", false},
+	}
+	for _, c := range cases {
+		r := bufio.NewReader(strings.NewReader(c.input))
+		h, err := readHeader(r)
+		if c.wantErr {
+			if err == nil {
+				t.Fatalf("%s: want error\n", c.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: err:%v\n", c.name, err)
+		}
+		got := ""
+		if h != nil && h.src != nil {
+			got = h.src.String()
+		}
+		if got != c.src {
+			t.Fatalf("%s: src %q, want %q\n", c.name, got, c.src)
+		}
+		//没有头时数据不能被消耗
+		if rest, _ := ioutil.ReadAll(r); string(rest) != c.rest {
+			t.Fatalf("%s: rest %q, want %q\n", c.name, rest, c.rest)
+		}
+	}
+}
+
+func TestTrusted(t *testing.T) {
+	config, err := ParseConfig(map[string]string{"proxyProtocol": "true", "proxyTrusted": "10.0.0.0/8, 192.168.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for addr, want := range map[string]bool{
+		"10.1.2.3:80":    true,
+		"192.168.1.1:80": true,
+		"192.168.1.2:80": false,
+	} {
+		tcpAddr, _ := net.ResolveTCPAddr("tcp", addr)
+		if got := config.trusted(tcpAddr); got != want {
+			t.Fatalf("trusted(%s) = %v, want %v\n", addr, got, want)
+		}
+	}
+
+	if config, _ := ParseConfig(map[string]string{}); config != nil {
+		t.Fatal("PROXY protocol enabled without proxyProtocol")
+	}
+	if _, err := ParseConfig(map[string]string{"proxyProtocol": "true"}); err == nil {
+		t.Fatal("PROXY protocol enabled without proxyTrusted")
+	}
+	if (&Config{}).trusted(&net.TCPAddr{IP: net.ParseIP("127.0.0.1")}) {
+		t.Fatal("empty Trusted should trust nobody")
+	}
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"strings"
+	"sync"
+)
+
+//主题以"."分段，订阅时"*"匹配任意一段，"#"只能放在最后，匹配剩余的零段或多段
+//如"match.*.score"可以匹配"match.1001.score"
+type Hub struct {
+	mu       sync.RWMutex
+	subs     map[string]*subscription
+	members  map[int64]*member
+	retained map[string]retainedMsg
+	seq      uint64 //保留消息的序号，递增，同一主题新的保留消息序号更大
+}
+
+type subscription struct {
+	segments []string
+	sessions map[int64]*Session
+}
+
+type retainedMsg struct {
+	seq uint64
+	msg interface{}
+}
+
+//订阅了至少一个主题的Session，记录每个主题最后收到的保留消息序号
+//发送不持有Hub的锁，序号保证较旧的保留消息不会在较新的之后到达
+type member struct {
+	session *Session
+	subs    int
+	mu      sync.Mutex
+	seqs    map[string]uint64
+}
+
+func (m *member) sendRetained(topic string, r retainedMsg) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if r.seq <= m.seqs[topic] {
+		return false
+	}
+	m.seqs[topic] = r.seq
+	return m.session.Send(r.msg) == nil
+}
+
+func NewHub() *Hub {
+	hub := &Hub{}
+	hub.subs = make(map[string]*subscription)
+	hub.members = make(map[int64]*member)
+	hub.retained = make(map[string]retainedMsg)
+	return hub
+}
+
+//订阅主题，Session关闭时自动取消订阅；已保留的匹配消息会立即发给该Session
+//保留的消息在锁外发出，同一主题的保留消息按序号只保留最新的，不会被并发的PublishRetained覆盖成旧值
+func (h *Hub) Subscribe(session *Session, pattern string) {
+	h.mu.Lock()
+	sub, ok := h.subs[pattern]
+	if !ok {
+		sub = &subscription{
+			segments: strings.Split(pattern, "."),
+			sessions: make(map[int64]*Session),
+		}
+		h.subs[pattern] = sub
+	}
+	if _, subscribed := sub.sessions[session.ID()]; subscribed {
+		h.mu.Unlock()
+		return
+	}
+	sub.sessions[session.ID()] = session
+	m, ok := h.members[session.ID()]
+	if !ok {
+		m = &member{session: session, seqs: make(map[string]uint64)}
+		h.members[session.ID()] = m
+	}
+	m.subs++
+	retained := make(map[string]retainedMsg)
+	for topic, r := range h.retained {
+		if matchTopic(sub.segments, strings.Split(topic, ".")) {
+			retained[topic] = r
+		}
+	}
+	h.mu.Unlock()
+
+	for topic, r := range retained {
+		m.sendRetained(topic, r)
+	}
+
+	session.AddCloseCallback(h, pattern, func() {
+		h.unsubscribe(session, pattern)
+	})
+	if session.isClosed() { //已关闭的Session不会再触发回调
+		h.unsubscribe(session, pattern)
+	}
+}
+
+func (h *Hub) Unsubscribe(session *Session, pattern string) {
+	session.DelCloseCallback(h, pattern)
+	h.unsubscribe(session, pattern)
+}
+
+func (h *Hub) unsubscribe(session *Session, pattern string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	sub, ok := h.subs[pattern]
+	if !ok {
+		return
+	}
+	if _, subscribed := sub.sessions[session.ID()]; !subscribed {
+		return
+	}
+	delete(sub.sessions, session.ID())
+	if len(sub.sessions) == 0 {
+		delete(h.subs, pattern)
+	}
+	if m := h.members[session.ID()]; m != nil {
+		if m.subs--; m.subs == 0 {
+			delete(h.members, session.ID())
+		}
+	}
+}
+
+//发布消息到主题，返回成功发送的Session数，一个Session匹配多个订阅时只发送一次
+func (h *Hub) Publish(topic string, msg interface{}) int {
+	h.mu.RLock()
+	members := h.subscribers(topic)
+	h.mu.RUnlock()
+
+	n := 0
+	for _, m := range members {
+		if m.session.Send(msg) == nil {
+			n++
+		}
+	}
+	return n
+}
+
+//发布并保留消息，之后订阅该主题的Session会先收到最后一条保留的消息
+//已经收到更新的保留消息的Session不会再收到这条，也不计入返回值
+func (h *Hub) PublishRetained(topic string, msg interface{}) int {
+	h.mu.Lock()
+	h.seq++
+	r := retainedMsg{seq: h.seq, msg: msg}
+	h.retained[topic] = r
+	members := h.subscribers(topic)
+	h.mu.Unlock()
+
+	n := 0
+	for _, m := range members {
+		if m.sendRetained(topic, r) {
+			n++
+		}
+	}
+	return n
+}
+
+func (h *Hub) ClearRetained(topic string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.retained, topic)
+}
+
+//发布到该主题时会收到消息的Session数
+func (h *Hub) Subscribers(topic string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subscribers(topic))
+}
+
+//持有锁时调用
+func (h *Hub) subscribers(topic string) map[int64]*member {
+	segments := strings.Split(topic, ".")
+	members := make(map[int64]*member)
+	for _, sub := range h.subs {
+		if !matchTopic(sub.segments, segments) {
+			continue
+		}
+		for id := range sub.sessions {
+			members[id] = h.members[id]
+		}
+	}
+	return members
+}
+
+func matchTopic(pattern, topic []string) bool {
+	for i, seg := range pattern {
+		if seg == "#" && i == len(pattern)-1 {
+			return true
+		}
+		if i >= len(topic) {
+			return false
+		}
+		if seg != "*" && seg != topic[i] {
+			return false
+		}
+	}
+	return len(pattern) == len(topic)
+}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMatchTopic(t *testing.T) {
+	tests := []struct {
+		pattern string
+		topic   string
+		want    bool
+	}{
+		{"match.*.score", "match.1001.score", true},
+		{"match.*.score", "match.1001.goal", false},
+		{"match.*.score", "match.1001.score.home", false},
+		{"match.*", "match", false},
+		{"match.#", "match", true},
+		{"match.#", "match.1001.score", true},
+		{"match.#.score", "match.1001.score", false},
+		{"chat.room", "chat.room", true},
+		{"chat.room", "chat.lobby", false},
+	}
+	for _, tt := range tests {
+		if got := matchTopic(strings.Split(tt.pattern, "."), strings.Split(tt.topic, ".")); got != tt.want {
+			t.Errorf("matchTopic(%q, %q) = %v, want %v\n", tt.pattern, tt.topic, got, tt.want)
+		}
+	}
+}
+
+func TestHub(t *testing.T) {
+	sm := NewSessionManager()
+	defer sm.Destroy()
+	newSub := func() (*Session, *testCodec) {
+		codec := newTestCodec()
+		return sm.NewSessionWithOptions(codec, SessionOptions{}), codec
+	}
+
+	hub := NewHub()
+	a, codecA := newSub()
+	b, codecB := newSub()
+	c, codecC := newSub()
+	hub.Subscribe(a, "match.*.score")
+	hub.Subscribe(a, "match.#") //匹配多个订阅时只收到一次
+	hub.Subscribe(b, "match.#")
+	hub.Subscribe(c, "chat.room")
+
+	if n := hub.Subscribers("match.1001.score"); n != 2 {
+		t.Fatalf("Subscribers = %d, want 2\n", n)
+	}
+	if n := hub.Publish("match.1001.score", "1:0"); n != 2 {
+		t.Fatalf("Publish sent to %d sessions, want 2\n", n)
+	}
+	if msgs := codecA.drain(); len(msgs) != 1 || msgs[0] != "1:0" {
+		t.Fatalf("a received %v\n", msgs)
+	}
+	if msgs := codecB.drain(); len(msgs) != 1 {
+		t.Fatalf("b received %v\n", msgs)
+	}
+	if msgs := codecC.drain(); len(msgs) != 0 {
+		t.Fatalf("c received %v\n", msgs)
+	}
+
+	hub.Unsubscribe(a, "match.#")
+	if n := hub.Publish("match.1001.goal", "goal"); n != 1 {
+		t.Fatalf("Publish after Unsubscribe sent to %d sessions, want 1\n", n)
+	}
+
+	//保留的消息在订阅时立即收到，清除后不再收到
+	hub.PublishRetained("match.1002.score", "2:1")
+	d, codecD := newSub()
+	hub.Subscribe(d, "match.*.score")
+	if msgs := codecD.drain(); len(msgs) != 1 || msgs[0] != "2:1" {
+		t.Fatalf("d received %v, want the retained message\n", msgs)
+	}
+	hub.ClearRetained("match.1002.score")
+	e, codecE := newSub()
+	hub.Subscribe(e, "match.*.score")
+	if msgs := codecE.drain(); len(msgs) != 0 {
+		t.Fatalf("e received %v after ClearRetained\n", msgs)
+	}
+
+	//Session关闭后通过关闭回调自动取消订阅
+	b.Close()
+	deadline := time.Now().Add(time.Second)
+	for hub.Subscribers("match.1001.goal") != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("closed session still subscribed\n")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	c.Close()
+	for hub.Subscribers("chat.room") != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("closed session still subscribed\n")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	//已关闭的Session订阅不会留下记录
+	hub.Subscribe(c, "chat.lobby")
+	if n := hub.Subscribers("chat.lobby"); n != 0 {
+		t.Fatalf("closed session subscribed, Subscribers = %d\n", n)
+	}
+}
+
+//订阅时收到的保留消息不能比之后发布的消息旧
+func TestHubRetainedOrder(t *testing.T) {
+	sm := NewSessionManager()
+	defer sm.Destroy()
+	hub := NewHub()
+
+	const n = 2000
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= n; i++ {
+			hub.PublishRetained("price", i)
+		}
+	}()
+
+	var codecs []*testCodec
+	for i := 0; i < 50; i++ {
+		codec := newTestCodec()
+		codec.sent = make(chan interface{}, n+1)
+		hub.Subscribe(sm.NewSessionWithOptions(codec, SessionOptions{}), "price")
+		codecs = append(codecs, codec)
+		time.Sleep(50 * time.Microsecond)
+	}
+	wg.Wait()
+
+	for i, codec := range codecs {
+		last := 0
+		for _, msg := range codec.drain() {
+			if msg.(int) < last {
+				t.Fatalf("subscriber %d received %d after %d\n", i, msg, last)
+			}
+			last = msg.(int)
+		}
+	}
+}
+
+//保留消息在锁外发送，一个阻塞的订阅者不会卡住整个Hub
+func TestHubSlowSubscriber(t *testing.T) {
+	sm := NewSessionManager()
+	defer sm.Destroy()
+	hub := NewHub()
+	hub.PublishRetained("price", 1)
+
+	slow := newTestCodec()
+	slow.block = make(chan struct{})
+	defer close(slow.block)
+	go hub.Subscribe(sm.NewSessionWithOptions(slow, SessionOptions{}), "price")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fast := newTestCodec()
+		hub.Subscribe(sm.NewSessionWithOptions(fast, SessionOptions{}), "chat")
+		hub.Publish("chat", "hi")
+		hub.Subscribers("price")
+		if msgs := fast.drain(); len(msgs) != 1 {
+			t.Errorf("fast subscriber received %v\n", msgs)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("hub blocked by a slow subscriber")
+	}
+}
+
+//同一主题较旧的保留消息不会在较新的之后发出
+func TestHubRetainedLatestWins(t *testing.T) {
+	sm := NewSessionManager()
+	defer sm.Destroy()
+	codec := newTestCodec()
+	m := &member{session: sm.NewSessionWithOptions(codec, SessionOptions{}), seqs: make(map[string]uint64)}
+
+	if !m.sendRetained("price", retainedMsg{seq: 2, msg: "new"}) {
+		t.Fatal("newer retained message not sent")
+	}
+	if m.sendRetained("price", retainedMsg{seq: 1, msg: "old"}) {
+		t.Fatal("older retained message sent after a newer one")
+	}
+	if !m.sendRetained("volume", retainedMsg{seq: 1, msg: "v"}) {
+		t.Fatal("sequence of another topic affected this one")
+	}
+	if msgs := codec.drain(); len(msgs) != 2 || msgs[0] != "new" || msgs[1] != "v" {
+		t.Fatalf("received %v\n", msgs)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build linux
+// +build linux
+
+package server
+
+import (
+	"syscall"
+)
+
+//syscall包中没有定义linux的SO_REUSEPORT
+const soReusePort = 0xf
+
+func reusePort(fd uintptr) error {
+	return syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, soReusePort, 1)
+}
+
+func setNonblock(fd uintptr) error {
+	return syscall.SetNonblock(int(fd), true)
+}
@@ -0,0 +1,16 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package server
+
+import (
+	"errors"
+)
+
+func reusePort(fd uintptr) error {
+	return errors.New("Listen: SO_REUSEPORT is not supported on this platform")
+}
+
+func setNonblock(fd uintptr) error {
+	return nil
+}
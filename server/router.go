@@ -0,0 +1,108 @@
+package server
+
+import (
+	"log"
+	"reflect"
+	"sync"
+
+	"github.com/gary163/seals/protocol"
+)
+
+type RouteFunc func(session *Session, msg interface{})
+
+//按消息类型分发的Handler，内置了Receive循环
+//路由可以按Go类型注册，也可以按协议注册表计算出来的消息名(Head)注册
+type Router struct {
+	mu       sync.RWMutex
+	protocol protocol.Protocol
+	types    map[reflect.Type]RouteFunc
+	names    map[string]RouteFunc
+	fallback RouteFunc
+}
+
+//protocol用于按消息名查找路由，可以为nil，此时只能按类型路由
+func NewRouter(p protocol.Protocol) *Router {
+	r := &Router{}
+	r.protocol = p
+	r.types = make(map[reflect.Type]RouteFunc)
+	r.names = make(map[string]RouteFunc)
+	return r
+}
+
+//按msg的类型注册路由，指针类型和非指针类型视为同一类型，与协议的Register保持一致
+func (r *Router) Route(msg interface{}, fn RouteFunc) {
+	t := routeType(msg)
+	if t == nil {
+		panic("Router:route msg is nil")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.types[t] = fn
+}
+
+//按消息名注册路由，名字需与协议Register后得到的Head一致
+func (r *Router) RouteName(name string, fn RouteFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.names[name] = fn
+}
+
+//未匹配到路由的消息交给fallback处理，不设置则丢弃
+func (r *Router) Fallback(fn RouteFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fallback = fn
+}
+
+func (r *Router) Handle(session *Session) {
+	defer session.Close()
+	for {
+		msg, err := session.Receive()
+		if err != nil {
+			return
+		}
+		r.Dispatch(session, msg)
+	}
+}
+
+//将一条消息分发给对应的路由
+//对端Call发来的*Request按Body的类型路由，回调收到的仍是*Request，以便用Reply应答
+func (r *Router) Dispatch(session *Session, msg interface{}) {
+	if fn := r.lookup(msg); fn != nil {
+		fn(session, msg)
+		return
+	}
+	log.Printf("Router:no route for message type %T\n", msg)
+}
+
+func (r *Router) lookup(msg interface{}) RouteFunc {
+	if req, ok := msg.(*Request); ok {
+		msg = req.Body
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if t := routeType(msg); t != nil {
+		if fn, ok := r.types[t]; ok {
+			return fn
+		}
+	}
+
+	if namer, ok := r.protocol.(protocol.Namer); ok && len(r.names) > 0 {
+		if name, ok := namer.Name(msg); ok {
+			if fn, ok := r.names[name]; ok {
+				return fn
+			}
+		}
+	}
+	return r.fallback
+}
+
+func routeType(msg interface{}) reflect.Type {
+	t := reflect.TypeOf(msg)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
@@ -0,0 +1,56 @@
+package rudp
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+//从server/client的配置中解析：mtu,sndWnd,rcvWnd,interval,noCongestion,linger,
+//handshakeTimeout,keepAlive,keepAliveTimeout
+func ParseConfig(cfg map[string]string) (Config, error) {
+	var c Config
+	var err error
+
+	ints := []struct {
+		key string
+		val *int
+	}{
+		{"mtu", &c.MTU},
+		{"sndWnd", &c.SndWnd},
+		{"rcvWnd", &c.RcvWnd},
+	}
+	for _, i := range ints {
+		if v, ok := cfg[i.key]; ok {
+			if *i.val, err = strconv.Atoi(v); err != nil {
+				return c, fmt.Errorf("rudp:%s %q is invalid", i.key, v)
+			}
+		}
+	}
+
+	durations := []struct {
+		key string
+		val *time.Duration
+	}{
+		{"interval", &c.Interval},
+		{"linger", &c.Linger},
+		{"handshakeTimeout", &c.HandshakeTimeout},
+		{"keepAlive", &c.KeepAlive},
+		{"keepAliveTimeout", &c.KeepAliveTimeout},
+	}
+	for _, d := range durations {
+		if v, ok := cfg[d.key]; ok {
+			if *d.val, err = time.ParseDuration(v); err != nil {
+				return c, fmt.Errorf("rudp:%s %q is invalid", d.key, v)
+			}
+		}
+	}
+
+	if v, ok := cfg["noCongestion"]; ok {
+		if c.NoCongestion, err = strconv.ParseBool(v); err != nil {
+			return c, fmt.Errorf("rudp:noCongestion %q is invalid", v)
+		}
+	}
+	c.setDefaults()
+	return c, nil
+}
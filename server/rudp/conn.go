@@ -0,0 +1,742 @@
+package rudp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMTU      = 1400
+	defaultWnd      = 128
+	defaultInterval = 10 * time.Millisecond
+	defaultLinger   = 2 * time.Second
+
+	defaultHandshakeTimeout = 5 * time.Second
+	defaultKeepAlive        = 5 * time.Second
+	defaultKeepAliveTimeout = 30 * time.Second
+
+	defaultRTO  = 200
+	minRTO      = 30
+	maxRTO      = 60000
+	fastResend  = 2  //被后续的ack跳过几次后快速重传
+	deadLink    = 20 //同一个segment重传超过该次数认为连接已断开
+	minSsthresh = 2
+)
+
+var ErrConnClosed = errors.New("rudp: use of closed connection")
+var ErrDeadLink = errors.New("rudp: peer not responding")
+var ErrHandshakeTimeout = errors.New("rudp: handshake timeout")
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "rudp: i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+type Config struct {
+	MTU          int           //一个数据报的最大字节数
+	SndWnd       int           //发送窗口，单位segment
+	RcvWnd       int           //接收窗口，单位segment
+	Interval     time.Duration //内部刷新间隔
+	NoCongestion bool          //关闭拥塞控制，只受收发窗口限制
+	Linger       time.Duration //Close时等待未确认数据的最长时间，FIN也最多重传这么久
+
+	HandshakeTimeout time.Duration //Dial等待SYN-ACK的最长时间
+	KeepAlive        time.Duration //超过该时间没有发送任何数据时发送保活segment
+	KeepAliveTimeout time.Duration //超过该时间没有收到任何数据认为连接已断开
+}
+
+func (cfg *Config) setDefaults() {
+	if cfg.MTU <= headerSize {
+		cfg.MTU = defaultMTU
+	}
+	if cfg.SndWnd <= 0 {
+		cfg.SndWnd = defaultWnd
+	}
+	if cfg.RcvWnd <= 0 {
+		cfg.RcvWnd = defaultWnd
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	if cfg.Linger <= 0 {
+		cfg.Linger = defaultLinger
+	}
+	if cfg.HandshakeTimeout <= 0 {
+		cfg.HandshakeTimeout = defaultHandshakeTimeout
+	}
+	if cfg.KeepAlive <= 0 {
+		cfg.KeepAlive = defaultKeepAlive
+	}
+	if cfg.KeepAliveTimeout <= 0 {
+		cfg.KeepAliveTimeout = defaultKeepAliveTimeout
+	}
+}
+
+type ackItem struct {
+	sn uint32
+	ts uint32
+}
+
+//基于UDP的可靠有序字节流，实现了net.Conn
+//序号+选择确认+累计确认，按RTT估算RTO超时重传，快速重传，慢启动/拥塞避免，接收端按序交付
+type Conn struct {
+	mu     sync.Mutex
+	cfg    Config
+	conv   uint32
+	mss    int
+	output func([]byte) error
+	onDead func()
+	local  net.Addr
+	remote net.Addr
+
+	sndUna   uint32
+	sndNxt   uint32
+	rcvNxt   uint32
+	sndQueue []*segment
+	sndBuf   []*segment
+	rcvBuf   map[uint32][]byte
+	rcvData  bytes.Buffer
+	ackList  []ackItem
+	buf      []byte
+
+	srtt, rttvar, rto uint32
+	cwnd, cwndCount   uint32
+	ssthresh          uint32
+	rmtWnd            uint32
+
+	established bool //收到SYN(服务端)或SYN-ACK(客户端)后才开始保活
+	estChan     chan struct{}
+	estOnce     sync.Once
+	lastSend    time.Time
+	lastRecv    time.Time
+
+	closing    bool //本端已Close，等待数据确认完毕
+	closeAt    time.Time
+	finAt      time.Time //第一次发送FIN的时间
+	finXmit    uint32
+	finRto     uint32
+	finResend  uint32
+	remoteFin  bool
+	dead       bool
+	err        error
+	readEvent  chan struct{}
+	writeEvent chan struct{}
+	dieChan    chan struct{} //本端Close或连接终止时关闭
+	dieOnce    sync.Once
+	doneChan   chan struct{} //连接彻底终止时关闭
+	doneOnce   sync.Once
+
+	readDeadline  time.Time
+	writeDeadline time.Time
+}
+
+//output用于发出一个数据报，onDead在连接彻底终止时调用，用于释放底层资源
+func newConn(conv uint32, cfg Config, local, remote net.Addr, output func([]byte) error, onDead func()) *Conn {
+	cfg.setDefaults()
+	c := &Conn{}
+	c.cfg = cfg
+	c.conv = conv
+	c.mss = cfg.MTU - headerSize
+	c.output = output
+	c.onDead = onDead
+	c.local = local
+	c.remote = remote
+	c.rcvBuf = make(map[uint32][]byte)
+	c.buf = make([]byte, 0, cfg.MTU)
+	c.rto = defaultRTO
+	c.cwnd = 1
+	c.ssthresh = uint32(cfg.SndWnd)
+	c.rmtWnd = uint32(cfg.RcvWnd)
+	c.readEvent = make(chan struct{}, 1)
+	c.writeEvent = make(chan struct{}, 1)
+	c.estChan = make(chan struct{})
+	c.dieChan = make(chan struct{})
+	c.doneChan = make(chan struct{})
+	c.lastSend = time.Now()
+	c.lastRecv = c.lastSend
+	go c.updateLoop()
+	return c
+}
+
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+//服务端收到SYN或客户端收到SYN-ACK后调用
+func (c *Conn) establishLocked() {
+	c.established = true
+	c.estOnce.Do(func() { close(c.estChan) })
+}
+
+//客户端发送SYN，按RTO退避重传，直到收到SYN-ACK或超时
+func (c *Conn) handshake() error {
+	timeout := time.NewTimer(c.cfg.HandshakeTimeout)
+	defer timeout.Stop()
+	rto := time.Duration(defaultRTO) * time.Millisecond
+	syn := &segment{conv: c.conv, cmd: cmdSyn}
+	for {
+		c.mu.Lock()
+		c.send(syn.encode(nil))
+		c.mu.Unlock()
+
+		resend := time.NewTimer(rto)
+		select {
+		case <-c.estChan:
+			resend.Stop()
+			return nil
+		case <-c.doneChan:
+			resend.Stop()
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			return c.err
+		case <-timeout.C:
+			resend.Stop()
+			return ErrHandshakeTimeout
+		case <-resend.C:
+			rto *= 2
+		}
+	}
+}
+
+func deadlineChan(deadline time.Time) (<-chan time.Time, func()) {
+	if deadline.IsZero() {
+		return nil, func() {}
+	}
+	timer := time.NewTimer(time.Until(deadline))
+	return timer.C, func() { timer.Stop() }
+}
+
+func (c *Conn) Read(p []byte) (int, error) {
+	for {
+		c.mu.Lock()
+		if c.rcvData.Len() > 0 {
+			n, _ := c.rcvData.Read(p)
+			c.mu.Unlock()
+			return n, nil
+		}
+		if c.closing {
+			c.mu.Unlock()
+			return 0, ErrConnClosed
+		}
+		if c.remoteFin {
+			c.mu.Unlock()
+			return 0, io.EOF
+		}
+		if c.dead {
+			err := c.err
+			c.mu.Unlock()
+			return 0, err
+		}
+		deadline := c.readDeadline
+		c.mu.Unlock()
+
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			return 0, timeoutError{}
+		}
+		timeout, stop := deadlineChan(deadline)
+		select {
+		case <-c.readEvent:
+		case <-c.dieChan:
+		case <-timeout:
+		}
+		stop()
+	}
+}
+
+func (c *Conn) Write(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		c.mu.Lock()
+		if c.closing {
+			c.mu.Unlock()
+			return n, ErrConnClosed
+		}
+		if c.dead {
+			err := c.err
+			c.mu.Unlock()
+			return n, err
+		}
+		if len(c.sndQueue) < 2*c.cfg.SndWnd {
+			n += c.enqueueLocked(p[n:])
+			c.mu.Unlock()
+			continue
+		}
+		deadline := c.writeDeadline
+		c.mu.Unlock()
+
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			return n, timeoutError{}
+		}
+		timeout, stop := deadlineChan(deadline)
+		select {
+		case <-c.writeEvent:
+		case <-c.dieChan:
+		case <-timeout:
+		}
+		stop()
+	}
+
+	c.mu.Lock()
+	c.flushLocked()
+	c.mu.Unlock()
+	return n, nil
+}
+
+//流模式：先填满队尾未满的segment，再按mss切分，返回放入的字节数
+func (c *Conn) enqueueLocked(p []byte) int {
+	n := 0
+	if l := len(c.sndQueue); l > 0 {
+		last := c.sndQueue[l-1]
+		if room := c.mss - len(last.data); room > 0 {
+			if room > len(p) {
+				room = len(p)
+			}
+			last.data = append(last.data, p[:room]...)
+			n += room
+		}
+	}
+	for n < len(p) && len(c.sndQueue) < 2*c.cfg.SndWnd {
+		size := len(p) - n
+		if size > c.mss {
+			size = c.mss
+		}
+		data := make([]byte, size, c.mss)
+		copy(data, p[n:n+size])
+		c.sndQueue = append(c.sndQueue, &segment{data: data})
+		n += size
+	}
+	return n
+}
+
+//关闭后Read/Write立即返回错误，未确认的数据在Linger时间内继续重传，之后发送FIN直到对端确认
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closing {
+		return ErrConnClosed
+	}
+	c.closing = true
+	c.closeAt = time.Now()
+	c.dieOnce.Do(func() { close(c.dieChan) })
+	c.flushLocked()
+	return nil
+}
+
+func (c *Conn) LocalAddr() net.Addr  { return c.local }
+func (c *Conn) RemoteAddr() net.Addr { return c.remote }
+
+func (c *Conn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readDeadline = t
+	c.writeDeadline = t
+	notify(c.readEvent)
+	notify(c.writeEvent)
+	return nil
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readDeadline = t
+	notify(c.readEvent)
+	return nil
+}
+
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeDeadline = t
+	notify(c.writeEvent)
+	return nil
+}
+
+//处理收到的一个数据报，其中可能包含多个segment
+func (c *Conn) input(data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.dead {
+		//对端没收到FIN-ACK时会重传FIN，终止后仍然确认
+		if seg, _, ok := decodeSegment(data); ok && seg.conv == c.conv && seg.cmd == cmdFin {
+			finAck := &segment{conv: c.conv, cmd: cmdFinAck}
+			c.output(finAck.encode(nil))
+		}
+		return
+	}
+
+	oldUna := c.sndUna
+	readable, flush := false, false
+	for len(data) > 0 {
+		seg, rest, ok := decodeSegment(data)
+		if !ok || seg.conv != c.conv {
+			break
+		}
+		data = rest
+		c.lastRecv = time.Now()
+
+		c.rmtWnd = uint32(seg.wnd)
+		c.parseUna(seg.una)
+
+		switch seg.cmd {
+		case cmdAck:
+			if now := currentMs(); seqDiff(now, seg.ts) >= 0 {
+				c.updateRTT(now - seg.ts)
+			}
+			c.parseAck(seg.sn)
+		case cmdPush:
+			if c.acceptPush(seg.sn, seg.data) {
+				c.ackList = append(c.ackList, ackItem{seg.sn, seg.ts})
+				readable = true
+			}
+		case cmdFin:
+			//FIN之前的数据没有收齐时不确认，等对端重传
+			if seqDiff(seg.sn, c.rcvNxt) <= 0 {
+				c.remoteFin = true
+				readable = true
+				c.write(&segment{conv: c.conv, cmd: cmdFinAck, una: c.rcvNxt})
+				flush = true
+			}
+		case cmdFinAck:
+			if c.finXmit > 0 {
+				c.terminateLocked(nil)
+				return
+			}
+		case cmdSyn:
+			//SYN-ACK可能丢失，每次收到SYN都回复
+			c.write(&segment{conv: c.conv, cmd: cmdSynAck, una: c.rcvNxt})
+			flush = true
+		case cmdSynAck:
+			c.establishLocked()
+		}
+	}
+
+	if newly := uint32(seqDiff(c.sndUna, oldUna)); newly > 0 {
+		c.growCwnd(newly)
+		notify(c.writeEvent)
+	}
+	if readable {
+		notify(c.readEvent)
+	}
+	if flush || len(c.ackList) > 0 {
+		c.flushLocked()
+	}
+	if c.remoteFin && c.closing {
+		c.terminateLocked(nil)
+	}
+}
+
+//把已经被对端累计确认的segment移出发送缓冲
+func (c *Conn) parseUna(una uint32) {
+	i := 0
+	for i < len(c.sndBuf) && seqDiff(una, c.sndBuf[i].sn) > 0 {
+		i++
+	}
+	if i > 0 {
+		c.sndBuf = c.sndBuf[i:]
+	}
+	c.shrinkUna()
+}
+
+func (c *Conn) parseAck(sn uint32) {
+	if seqDiff(sn, c.sndUna) < 0 || seqDiff(sn, c.sndNxt) >= 0 {
+		return
+	}
+	for i, seg := range c.sndBuf {
+		if seg.sn == sn {
+			c.sndBuf = append(c.sndBuf[:i], c.sndBuf[i+1:]...)
+			break
+		}
+		if seqDiff(seg.sn, sn) < 0 {
+			seg.fastack++
+		}
+	}
+	c.shrinkUna()
+}
+
+func (c *Conn) shrinkUna() {
+	if len(c.sndBuf) > 0 {
+		c.sndUna = c.sndBuf[0].sn
+	} else {
+		c.sndUna = c.sndNxt
+	}
+}
+
+//接收一个数据segment，返回是否需要确认(窗口外的segment不确认，等待对端重传)
+func (c *Conn) acceptPush(sn uint32, data []byte) bool {
+	if seqDiff(sn, c.rcvNxt+uint32(c.rcvWndFree())) >= 0 {
+		return false
+	}
+	if seqDiff(sn, c.rcvNxt) < 0 {
+		return true //重复的segment，之前的确认可能丢了
+	}
+	if _, ok := c.rcvBuf[sn]; !ok {
+		c.rcvBuf[sn] = append([]byte(nil), data...)
+	}
+	for {
+		data, ok := c.rcvBuf[c.rcvNxt]
+		if !ok {
+			break
+		}
+		c.rcvData.Write(data)
+		delete(c.rcvBuf, c.rcvNxt)
+		c.rcvNxt++
+	}
+	return true
+}
+
+//接收窗口剩余大小，未被读取的数据也占用窗口
+func (c *Conn) rcvWndFree() int {
+	used := len(c.rcvBuf) + (c.rcvData.Len()+c.mss-1)/c.mss
+	if free := c.cfg.RcvWnd - used; free > 0 {
+		return free
+	}
+	return 0
+}
+
+func (c *Conn) updateRTT(rtt uint32) {
+	if c.srtt == 0 {
+		c.srtt = rtt
+		c.rttvar = rtt / 2
+	} else {
+		delta := int32(rtt - c.srtt)
+		if delta < 0 {
+			delta = -delta
+		}
+		c.rttvar = (3*c.rttvar + uint32(delta)) / 4
+		c.srtt = (7*c.srtt + rtt) / 8
+		if c.srtt < 1 {
+			c.srtt = 1
+		}
+	}
+	variance := 4 * c.rttvar
+	if interval := uint32(c.cfg.Interval / time.Millisecond); variance < interval {
+		variance = interval
+	}
+	c.rto = c.srtt + variance
+	if c.rto < minRTO {
+		c.rto = minRTO
+	}
+	if c.rto > maxRTO {
+		c.rto = maxRTO
+	}
+}
+
+//慢启动阶段每确认一个segment窗口加一，拥塞避免阶段每确认一个窗口的数据加一
+func (c *Conn) growCwnd(acked uint32) {
+	for i := uint32(0); i < acked; i++ {
+		if c.cwnd < c.ssthresh {
+			c.cwnd++
+		} else {
+			c.cwndCount++
+			if c.cwndCount >= c.cwnd {
+				c.cwnd++
+				c.cwndCount = 0
+			}
+		}
+	}
+	if limit := uint32(c.cfg.SndWnd); c.cwnd > limit {
+		c.cwnd = limit
+	}
+}
+
+func (c *Conn) send(data []byte) {
+	c.lastSend = time.Now()
+	c.output(data)
+}
+
+func (c *Conn) write(seg *segment) {
+	if len(c.buf)+headerSize+len(seg.data) > c.cfg.MTU {
+		c.send(c.buf)
+		c.buf = c.buf[:0]
+	}
+	c.buf = seg.encode(c.buf)
+}
+
+//发送确认、新数据和需要重传的数据
+func (c *Conn) flushLocked() {
+	if c.dead {
+		return
+	}
+	now := currentMs()
+	wnd := uint16(c.rcvWndFree())
+
+	for _, ack := range c.ackList {
+		c.write(&segment{conv: c.conv, cmd: cmdAck, wnd: wnd, ts: ack.ts, sn: ack.sn, una: c.rcvNxt})
+	}
+	c.ackList = c.ackList[:0]
+
+	window := uint32(c.cfg.SndWnd)
+	if c.rmtWnd < window {
+		window = c.rmtWnd
+	}
+	if !c.cfg.NoCongestion && c.cwnd < window {
+		window = c.cwnd
+	}
+	if window == 0 {
+		window = 1 //对端窗口为0时仍保留一个segment用于探测
+	}
+
+	for len(c.sndQueue) > 0 && uint32(seqDiff(c.sndNxt, c.sndUna)) < window {
+		seg := c.sndQueue[0]
+		c.sndQueue = c.sndQueue[1:]
+		seg.conv = c.conv
+		seg.cmd = cmdPush
+		seg.sn = c.sndNxt
+		c.sndNxt++
+		c.sndBuf = append(c.sndBuf, seg)
+	}
+	if len(c.sndQueue) < 2*c.cfg.SndWnd {
+		notify(c.writeEvent)
+	}
+
+	lost, fast := false, false
+	for _, seg := range c.sndBuf {
+		send := false
+		switch {
+		case seg.xmit == 0:
+			send = true
+			seg.rto = c.rto
+			seg.resendAt = now + seg.rto
+		case seqDiff(now, seg.resendAt) >= 0:
+			send = true
+			lost = true
+			seg.rto *= 2
+			if seg.rto > maxRTO {
+				seg.rto = maxRTO
+			}
+			seg.resendAt = now + seg.rto
+		case seg.fastack >= fastResend:
+			send = true
+			fast = true
+			seg.fastack = 0
+			seg.resendAt = now + seg.rto
+		}
+		if !send {
+			continue
+		}
+		seg.xmit++
+		seg.ts = now
+		seg.wnd = wnd
+		seg.una = c.rcvNxt
+		c.write(seg)
+		if seg.xmit >= deadLink {
+			c.terminateLocked(ErrDeadLink)
+			return
+		}
+	}
+	if len(c.buf) > 0 {
+		c.send(c.buf)
+		c.buf = c.buf[:0]
+	}
+
+	if fast {
+		inflight := uint32(seqDiff(c.sndNxt, c.sndUna))
+		c.ssthresh = inflight / 2
+		if c.ssthresh < minSsthresh {
+			c.ssthresh = minSsthresh
+		}
+		c.cwnd = c.ssthresh + fastResend
+	}
+	if lost {
+		c.ssthresh = window / 2
+		if c.ssthresh < minSsthresh {
+			c.ssthresh = minSsthresh
+		}
+		c.cwnd = 1
+	}
+}
+
+//发送或重传FIN，按RTO退避，收到FIN-ACK或超过Linger后终止
+//对端已经关闭时不需要FIN，直接终止
+func (c *Conn) finLocked() {
+	if c.remoteFin {
+		c.terminateLocked(nil)
+		return
+	}
+	now := currentMs()
+	if c.finXmit == 0 {
+		c.finAt = time.Now()
+		c.finRto = c.rto
+	} else {
+		if seqDiff(now, c.finResend) < 0 {
+			return
+		}
+		if time.Since(c.finAt) > c.cfg.Linger {
+			c.terminateLocked(nil)
+			return
+		}
+		c.finRto *= 2
+		if c.finRto > maxRTO {
+			c.finRto = maxRTO
+		}
+	}
+	c.finXmit++
+	c.finResend = now + c.finRto
+	fin := &segment{conv: c.conv, cmd: cmdFin, wnd: uint16(c.rcvWndFree()), sn: c.sndNxt, una: c.rcvNxt}
+	c.send(fin.encode(nil))
+}
+
+//空闲时发送保活，长时间收不到对端的数据时终止连接
+func (c *Conn) keepAliveLocked() {
+	if !c.established {
+		return
+	}
+	now := time.Now()
+	if now.Sub(c.lastRecv) > c.cfg.KeepAliveTimeout {
+		c.terminateLocked(ErrDeadLink)
+		return
+	}
+	if now.Sub(c.lastSend) >= c.cfg.KeepAlive {
+		ping := &segment{conv: c.conv, cmd: cmdPing, wnd: uint16(c.rcvWndFree()), una: c.rcvNxt}
+		c.send(ping.encode(nil))
+	}
+}
+
+//连接终止：err为nil表示正常关闭
+func (c *Conn) terminateLocked(err error) {
+	if c.dead {
+		return
+	}
+	if err == nil {
+		err = ErrConnClosed
+	}
+	c.dead = true
+	c.err = err
+	c.dieOnce.Do(func() { close(c.dieChan) })
+	c.doneOnce.Do(func() { close(c.doneChan) })
+	if c.onDead != nil {
+		go c.onDead()
+	}
+}
+
+func (c *Conn) updateLoop() {
+	ticker := time.NewTicker(c.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.doneChan:
+			return
+		case <-ticker.C:
+			c.mu.Lock()
+			c.flushLocked()
+			if c.closing {
+				drained := len(c.sndQueue) == 0 && len(c.sndBuf) == 0
+				if drained || c.remoteFin || time.Since(c.closeAt) > c.cfg.Linger {
+					c.finLocked()
+				}
+			}
+			if !c.dead {
+				c.keepAliveLocked()
+			}
+			c.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package rudp
+
+import (
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	maxDatagramSize      = 65535
+	defaultAcceptBacklog = 128
+)
+
+//在一个UDP socket上按(远端地址,conv)拆分出多个Conn
+type Listener struct {
+	conn      *net.UDPConn
+	cfg       Config
+	conns     map[string]*Conn
+	connsMu   sync.Mutex
+	accept    chan *Conn
+	closeChan chan struct{}
+	closeOnce sync.Once
+	err       error
+}
+
+func Listen(addr string, cfg Config) (*Listener, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Listener{}
+	l.conn = conn
+	l.cfg = cfg
+	l.conns = make(map[string]*Conn)
+	l.accept = make(chan *Conn, defaultAcceptBacklog)
+	l.closeChan = make(chan struct{})
+	go l.readLoop()
+	return l, nil
+}
+
+func (l *Listener) readLoop() {
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, addr, err := l.conn.ReadFromUDP(buf)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			l.closeWithError(err)
+			return
+		}
+
+		seg, _, ok := decodeSegment(buf[:n])
+		if !ok {
+			continue
+		}
+		if conn := l.getOrCreate(addr, seg); conn != nil {
+			conn.input(buf[:n])
+		} else if seg.cmd == cmdFin {
+			//连接已经终止，对端的FIN-ACK丢失后重传的FIN直接确认
+			finAck := &segment{conv: seg.conv, cmd: cmdFinAck}
+			l.conn.WriteToUDP(finAck.encode(nil), addr)
+		}
+	}
+}
+
+func (l *Listener) getOrCreate(addr *net.UDPAddr, first segment) *Conn {
+	key := addr.String()
+	l.connsMu.Lock()
+	defer l.connsMu.Unlock()
+
+	if conn, ok := l.conns[key]; ok && conn.conv == first.conv {
+		return conn
+	}
+	//只有SYN才会建立新连接，未知连接的数据segment直接丢弃
+	if first.cmd != cmdSyn {
+		return nil
+	}
+
+	var conn *Conn
+	conn = newConn(first.conv, l.cfg, l.conn.LocalAddr(), addr, func(data []byte) error {
+		_, err := l.conn.WriteToUDP(data, addr)
+		return err
+	}, func() {
+		l.connsMu.Lock()
+		defer l.connsMu.Unlock()
+		if l.conns[key] == conn {
+			delete(l.conns, key)
+		}
+	})
+	conn.mu.Lock()
+	conn.establishLocked()
+	conn.mu.Unlock()
+
+	select {
+	case l.accept <- conn:
+	default: //accept队列满，丢弃，等对端重传
+		conn.mu.Lock()
+		conn.terminateLocked(ErrConnClosed)
+		conn.mu.Unlock()
+		return nil
+	}
+	if old, ok := l.conns[key]; ok { //同一地址上的新连接，旧连接作废
+		old.mu.Lock()
+		old.remoteFin = true
+		old.mu.Unlock()
+		notify(old.readEvent)
+	}
+	l.conns[key] = conn
+	return conn
+}
+
+func (l *Listener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.accept:
+		return conn, nil
+	case <-l.closeChan:
+		if l.err != nil {
+			return nil, l.err
+		}
+		return nil, ErrConnClosed
+	}
+}
+
+//关闭监听socket，已建立的连接也随之失效
+func (l *Listener) Close() error {
+	return l.closeWithError(nil)
+}
+
+//cause为监听socket读取失败的原因，之后Accept返回该错误
+func (l *Listener) closeWithError(cause error) error {
+	var err error
+	l.closeOnce.Do(func() {
+		l.err = cause
+		close(l.closeChan)
+
+		l.connsMu.Lock()
+		conns := make([]*Conn, 0, len(l.conns))
+		for _, conn := range l.conns {
+			conns = append(conns, conn)
+		}
+		l.connsMu.Unlock()
+		//socket关闭后无法重传，尽力给每个连接发一次FIN，对端收不到时靠保活超时发现
+		for _, conn := range conns {
+			conn.mu.Lock()
+			if !conn.dead && !conn.remoteFin {
+				fin := &segment{conv: conn.conv, cmd: cmdFin, sn: conn.sndNxt, una: conn.rcvNxt}
+				conn.send(fin.encode(nil))
+			}
+			conn.terminateLocked(ErrConnClosed)
+			conn.mu.Unlock()
+		}
+		err = l.conn.Close()
+	})
+	return err
+}
+
+func (l *Listener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
+
+//建立到addr的连接，使用独立的UDP socket，握手完成或超时后返回
+func Dial(addr string, cfg Config) (*Conn, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	udpConn, err := net.DialUDP("udp", nil, udpAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	conv := r.Uint32()
+	conn := newConn(conv, cfg, udpConn.LocalAddr(), udpAddr, func(data []byte) error {
+		_, err := udpConn.Write(data)
+		return err
+	}, func() {
+		udpConn.Close()
+	})
+
+	go func() {
+		buf := make([]byte, maxDatagramSize)
+		for {
+			n, err := udpConn.Read(buf)
+			if err != nil {
+				if ne, ok := err.(net.Error); ok && ne.Temporary() {
+					continue
+				}
+				conn.mu.Lock()
+				conn.terminateLocked(err)
+				conn.mu.Unlock()
+				return
+			}
+			conn.input(buf[:n])
+		}
+	}()
+
+	if err := conn.handshake(); err != nil {
+		conn.mu.Lock()
+		conn.terminateLocked(err)
+		conn.mu.Unlock()
+		return nil, err
+	}
+	return conn, nil
+}
@@ -0,0 +1,219 @@
+package rudp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gary163/seals/protocol"
+	_ "github.com/gary163/seals/protocol/binary"
+	"github.com/gary163/seals/server"
+)
+
+//两个Conn直接相连，数据报随机丢弃和乱序
+func lossyPair(loss float64) (*Conn, *Conn) {
+	return linkPair(Config{Interval: 5 * time.Millisecond, Linger: 20 * time.Second}, loss, nil)
+}
+
+//cut不为nil且非0时丢弃所有数据报，模拟链路中断
+func linkPair(cfg Config, loss float64, cut *int32) (*Conn, *Conn) {
+	var a, b *Conn
+	var mu sync.Mutex
+	r := rand.New(rand.NewSource(1))
+	link := func(dst **Conn) func([]byte) error {
+		return func(data []byte) error {
+			if cut != nil && atomic.LoadInt32(cut) != 0 {
+				return nil
+			}
+			mu.Lock()
+			drop := r.Float64() < loss
+			delay := time.Duration(r.Intn(20)) * time.Millisecond
+			mu.Unlock()
+			if drop {
+				return nil
+			}
+			data = append([]byte(nil), data...)
+			time.AfterFunc(delay, func() { (*dst).input(data) })
+			return nil
+		}
+	}
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}
+	a = newConn(1, cfg, addr, addr, link(&b), nil)
+	b = newConn(1, cfg, addr, addr, link(&a), nil)
+	return a, b
+}
+
+func TestLossyStream(t *testing.T) {
+	a, b := lossyPair(0.2)
+	defer b.Close()
+
+	data := make([]byte, 256*1024)
+	rand.Read(data)
+	go func() {
+		a.Write(data)
+		a.Close()
+	}()
+
+	b.SetReadDeadline(time.Now().Add(20 * time.Second))
+	recv, err := ioutil.ReadAll(b)
+	if err != nil {
+		t.Fatalf("Read err:%v\n", err)
+	}
+	if !bytes.Equal(recv, data) {
+		t.Fatalf("recv %d bytes not equal send %d bytes\n", len(recv), len(data))
+	}
+}
+
+//FIN丢失后会重传，两端都能正常终止
+func TestLossyClose(t *testing.T) {
+	a, b := lossyPair(0.3)
+	go func() {
+		a.Write([]byte("bye"))
+		a.Close()
+	}()
+
+	b.SetReadDeadline(time.Now().Add(10 * time.Second))
+	recv, err := ioutil.ReadAll(b)
+	if err != nil || string(recv) != "bye" {
+		t.Fatalf("ReadAll = %q err:%v\n", recv, err)
+	}
+	b.Close()
+	for _, c := range []*Conn{a, b} {
+		select {
+		case <-c.doneChan:
+		case <-time.After(10 * time.Second):
+			t.Fatal("connection not terminated after close")
+		}
+	}
+}
+
+//空闲连接靠保活维持，链路中断后在keepAliveTimeout内终止
+func TestKeepAlive(t *testing.T) {
+	var cut int32
+	cfg := Config{Interval: 5 * time.Millisecond, KeepAlive: 20 * time.Millisecond, KeepAliveTimeout: 200 * time.Millisecond}
+	a, b := linkPair(cfg, 0, &cut)
+	defer b.Close()
+	for _, c := range []*Conn{a, b} {
+		c.mu.Lock()
+		c.establishLocked()
+		c.mu.Unlock()
+	}
+
+	time.Sleep(500 * time.Millisecond)
+	if _, err := a.Write([]byte("ping")); err != nil {
+		t.Fatalf("idle connection died: %v\n", err)
+	}
+
+	atomic.StoreInt32(&cut, 1)
+	a.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 16)
+	b.Read(buf)
+	if _, err := a.Read(buf); err != ErrDeadLink {
+		t.Fatalf("Read after the link is cut err:%v, want ErrDeadLink\n", err)
+	}
+}
+
+//没有SYN的数据不会建立连接，对端不回复时Dial超时
+func TestHandshake(t *testing.T) {
+	l, err := Listen("127.0.0.1:0", Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	raw, err := net.Dial("udp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	push := &segment{conv: 7, cmd: cmdPush, data: []byte("hello")}
+	raw.Write(push.encode(nil))
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if conn, err := l.Accept(); err == nil {
+			accepted <- conn
+		}
+	}()
+	select {
+	case <-accepted:
+		t.Fatal("connection accepted without SYN")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	conn, err := Dial(l.Addr().String(), Config{})
+	if err != nil {
+		t.Fatalf("Dial err:%v\n", err)
+	}
+	defer conn.Close()
+	select {
+	case <-accepted:
+	case <-time.After(time.Second):
+		t.Fatal("connection not accepted after SYN")
+	}
+
+	//只收不回的UDP socket
+	silent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	start := time.Now()
+	if _, err := Dial(silent.LocalAddr().String(), Config{HandshakeTimeout: 300 * time.Millisecond}); err != ErrHandshakeTimeout {
+		t.Fatalf("Dial err:%v, want ErrHandshakeTimeout\n", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("Dial did not honour handshakeTimeout")
+	}
+}
+
+func TestRUDPEcho(t *testing.T) {
+	proto, err := protocol.NewProtocol("binary", `{"fixlen":{}}`)
+	if err != nil {
+		t.Fatalf("New protocol err:%v\n", err)
+	}
+
+	srv, err := server.NewServer("rudpServer", `{"addr":"127.0.0.1:0","interval":"5ms"}`, proto, server.HandlerFunc(func(session *server.Session) {
+		defer session.Close()
+		for {
+			msg, err := session.Receive()
+			if err != nil {
+				return
+			}
+			session.Send(msg)
+		}
+	}))
+	if err != nil {
+		t.Fatalf("New server err:%v\n", err)
+	}
+	go srv.Run()
+	defer srv.Stop()
+
+	cli, err := server.NewClient("rudpClient", `{"addr":"`+srv.(*rudpServer).listener.Addr().String()+`","connNum":"10","interval":"5ms"}`, proto, server.HandlerFunc(func(session *server.Session) {
+		defer session.Close()
+		for i := 0; i < 50; i++ {
+			msg := make([]byte, rand.Intn(4000)+1)
+			rand.Read(msg)
+			if err := session.Send(msg); err != nil {
+				t.Errorf("Send err:%v\n", err)
+				return
+			}
+			recv, err := session.Receive()
+			if err != nil {
+				t.Errorf("Receive err:%v\n", err)
+				return
+			}
+			if !bytes.Equal(msg, recv.([]byte)) {
+				t.Errorf("recv msg not equal send msg\n")
+			}
+		}
+	}))
+	if err != nil {
+		t.Fatalf("New client err:%v\n", err)
+	}
+	cli.Run()
+}
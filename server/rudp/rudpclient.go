@@ -0,0 +1,85 @@
+package rudp
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+
+	"github.com/gary163/seals/protocol"
+	"github.com/gary163/seals/server"
+)
+
+const defaultConnNum = 1
+
+type rudpClient struct {
+	addr        string
+	config      Config
+	sessionOpts server.SessionOptions
+	handler     server.Handler
+	protocol    protocol.Protocol
+	sm          *server.SessionManager
+	connNum     int
+	wg          sync.WaitGroup
+}
+
+func (c *rudpClient) Init(config string, protocol protocol.Protocol, handler server.Handler, sm *server.SessionManager) error {
+	var cfg map[string]string
+	json.Unmarshal([]byte(config),&cfg)
+
+	if _,ok := cfg["addr"]; !ok {
+		return errors.New("RudpClient:Missing addr parameter")
+	}
+	if _,ok := cfg["sendChanSize"]; !ok {
+		cfg["sendChanSize"] = strconv.Itoa(defaultSendChanSize)
+	}
+	if _,ok := cfg["connNum"]; !ok {
+		cfg["connNum"] = strconv.Itoa(defaultConnNum)
+	}
+
+	c.addr      = cfg["addr"]
+	c.connNum,_ = strconv.Atoi(cfg["connNum"])
+	var err error
+	if c.config,err = ParseConfig(cfg); err != nil {
+		return err
+	}
+	if c.sessionOpts,err = server.ParseSessionOptions(cfg); err != nil {
+		return err
+	}
+	c.handler  = handler
+	c.protocol = protocol
+	c.sm       = sm
+	return nil
+}
+
+func (c *rudpClient) Run() {
+	for i:=0; i<c.connNum; i++ {
+		c.wg.Add(1)
+		go func(){
+			defer c.wg.Done()
+			conn,err := Dial(c.addr, c.config)
+			if err != nil {
+				log.Printf("Client dial got a err:%v\n",err)
+				return
+			}
+			codec,_ := c.protocol.NewCodec(conn)
+			session := c.sm.NewSessionWithInfo(codec, c.sessionOpts, server.ConnInfo{
+				Transport:  "rudp",
+				LocalAddr:  conn.LocalAddr(),
+				RemoteAddr: conn.RemoteAddr(),
+			})
+			c.handler.Handle(session)
+		}()
+	}
+	c.wg.Wait()
+}
+
+func (c *rudpClient) Close() error {
+	c.sm.Destroy()
+	return nil
+}
+
+func init(){
+	server.RegisterClient("rudpClient", &rudpClient{})
+}
@@ -0,0 +1,100 @@
+package rudp
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+
+	"github.com/gary163/seals/protocol"
+	"github.com/gary163/seals/server"
+)
+
+const (
+	defaultMaxConn      = 200000
+	defaultSendChanSize = 1024
+	defaultAddr         = "0.0.0.0:0"
+)
+
+//可靠UDP服务端，连接是有序字节流，fixlen,json,protobuf等协议可以直接使用
+type rudpServer struct {
+	addr        string
+	maxConn     int//最大连接数
+	listener    *Listener
+	config      Config
+	sessionOpts server.SessionOptions
+	protocol    protocol.Protocol
+	handler     server.Handler
+	sm          *server.SessionManager
+}
+
+func init() {
+	server.RegisterServer("rudpServer",&rudpServer{})
+}
+
+func (s *rudpServer) Init(config string, protocol protocol.Protocol, handler server.Handler, sm *server.SessionManager) error {
+	var cfg map[string]string
+	err := json.Unmarshal([]byte(config),&cfg)
+	if err != nil {
+		return err
+	}
+
+	if _,ok := cfg["maxConn"]; !ok {
+		cfg["maxConn"] = strconv.Itoa(defaultMaxConn)
+	}
+
+	if _,ok := cfg["sendChanSize"]; !ok {
+		cfg["sendChanSize"] = strconv.Itoa(defaultSendChanSize)
+	}
+
+	if _,ok := cfg["addr"]; !ok {
+		cfg["addr"] = defaultAddr
+	}
+
+	s.maxConn,_ = strconv.Atoi(cfg["maxConn"])
+	s.addr      = cfg["addr"]
+	if s.config,err = ParseConfig(cfg); err != nil {
+		return err
+	}
+	if s.sessionOpts,err = server.ParseSessionOptions(cfg); err != nil {
+		return err
+	}
+	s.protocol = protocol
+	s.handler  = handler
+	s.sm       = sm
+
+	if s.listener,err = Listen(s.addr, s.config); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *rudpServer) Run() error {
+	for{
+		conn,err := s.listener.Accept()
+		if err != nil {
+			return err
+		}
+
+		if s.sm.Len() > int64(s.maxConn) {
+			log.Printf("Too manay connection:%d\n",s.maxConn)
+			conn.Close()
+			continue
+		}
+
+		go func(){
+			codec,_ := s.protocol.NewCodec(conn)
+			session := s.sm.NewSessionWithInfo(codec, s.sessionOpts, server.ConnInfo{
+				Transport:  "rudp",
+				LocalAddr:  conn.LocalAddr(),
+				RemoteAddr: conn.RemoteAddr(),
+			})
+			s.handler.Handle(session)
+		}()
+	}
+}
+
+func (s *rudpServer) Stop() error {
+	s.listener.Close()
+	s.sm.Destroy()
+	return nil
+}
@@ -0,0 +1,80 @@
+package rudp
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+const (
+	cmdPush   uint8 = 1 //数据
+	cmdAck    uint8 = 2 //对单个sn的确认(选择确认)，ts回显发送时间用于计算RTT
+	cmdFin    uint8 = 3 //关闭，sn为发送方最后一个数据segment之后的序号
+	cmdSyn    uint8 = 4 //建立连接，服务端收到后才创建Conn
+	cmdSynAck uint8 = 5 //对SYN的确认
+	cmdFinAck uint8 = 6 //对FIN的确认
+	cmdPing   uint8 = 7 //空闲时的保活，只用于刷新对端的接收时间
+
+	headerSize = 21
+)
+
+var epoch = time.Now()
+
+//毫秒时间戳，用于RTT和重传计时
+func currentMs() uint32 {
+	return uint32(time.Since(epoch) / time.Millisecond)
+}
+
+//所有的segment头部字段都会带上una和wnd，接收方据此做累计确认和流量控制
+//conv(4) cmd(1) wnd(2) ts(4) sn(4) una(4) len(2) data
+type segment struct {
+	conv uint32
+	cmd  uint8
+	wnd  uint16
+	ts   uint32
+	sn   uint32
+	una  uint32
+	data []byte
+
+	//发送方状态
+	resendAt uint32
+	rto      uint32
+	xmit     uint32
+	fastack  uint32
+}
+
+func (seg *segment) encode(buf []byte) []byte {
+	var head [headerSize]byte
+	binary.LittleEndian.PutUint32(head[0:], seg.conv)
+	head[4] = seg.cmd
+	binary.LittleEndian.PutUint16(head[5:], seg.wnd)
+	binary.LittleEndian.PutUint32(head[7:], seg.ts)
+	binary.LittleEndian.PutUint32(head[11:], seg.sn)
+	binary.LittleEndian.PutUint32(head[15:], seg.una)
+	binary.LittleEndian.PutUint16(head[19:], uint16(len(seg.data)))
+	buf = append(buf, head[:]...)
+	return append(buf, seg.data...)
+}
+
+//解析一个segment，返回剩余的数据，格式错误时ok为false
+func decodeSegment(data []byte) (seg segment, rest []byte, ok bool) {
+	if len(data) < headerSize {
+		return seg, nil, false
+	}
+	seg.conv = binary.LittleEndian.Uint32(data[0:])
+	seg.cmd = data[4]
+	seg.wnd = binary.LittleEndian.Uint16(data[5:])
+	seg.ts = binary.LittleEndian.Uint32(data[7:])
+	seg.sn = binary.LittleEndian.Uint32(data[11:])
+	seg.una = binary.LittleEndian.Uint32(data[15:])
+	length := int(binary.LittleEndian.Uint16(data[19:]))
+	if len(data) < headerSize+length {
+		return seg, nil, false
+	}
+	seg.data = data[headerSize : headerSize+length]
+	return seg, data[headerSize+length:], true
+}
+
+//处理序号回绕的比较
+func seqDiff(a, b uint32) int32 {
+	return int32(a - b)
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"fmt"
+	"reflect"
 	"sync"
 
 	"github.com/gary163/seals/protocol"
@@ -32,16 +33,31 @@ func RegisterServer(name string, adapter Server) {
 }
 
 func NewServer(name string, config string, protocol protocol.Protocol, handler Handler) (Server, error){
+	return NewServerWithManager(name, config, protocol, handler, NewSessionManager())
+}
+
+//使用指定的SessionManager创建Server，可以先在sm上注册钩子
+func NewServerWithManager(name string, config string, protocol protocol.Protocol, handler Handler, sm *SessionManager) (Server, error){
+	adpatersMu.RLock()
 	adapter, ok := adapters[name]
+	adpatersMu.RUnlock()
 	if !ok {
 		err := fmt.Errorf("Server: unknown adapter name %q (forgot to import?)", name)
 		return nil,err
 	}
-	sm := NewSessionManager()
+	adapter = newInstance(adapter).(Server)
 	err := adapter.Init(config, protocol, handler, sm)
 	if err != nil {
 		return nil,err
 	}
 
 	return adapter,nil
-}
\ No newline at end of file
+}
+//注册的adapter只作为原型，每次创建都使用新的实例，同一种adapter可以同时创建多个
+func newInstance(adapter interface{}) interface{} {
+	t := reflect.TypeOf(adapter)
+	if t.Kind() != reflect.Ptr {
+		return adapter
+	}
+	return reflect.New(t.Elem()).Interface()
+}
@@ -1,9 +1,11 @@
 package server
 
 import (
+	"context"
 	"errors"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/gary163/seals/protocol"
 )
@@ -15,19 +17,39 @@ type SessionManager struct {
 	mu sync.RWMutex
 	wg sync.WaitGroup
 	destroyOnce sync.Once
+	stopped bool//Drain或Destroy开始后不再接收新的Session
+	hooks sessionHooks
+	hooksMu sync.RWMutex
+	interceptors []Interceptor
 }
 
 type Session struct {
 	id        int64
+	callID    uint64
+	lastRead  int64 //最后一次收到消息的时间(UnixNano)
+	lastWrite int64 //最后一次成功发出消息的时间(UnixNano)
 	sendChan  chan interface{}
 	codec     protocol.Codec
 	closeFlag int32
 	sendMu    sync.RWMutex //使用读写锁，发送时可以并发写入buffchan,提高并发能力
-	recvMu    sync.Mutex
+	recvLock  chan struct{} //接收锁，用通道实现以便Call可以尝试获取
+	recvQueue []received    //Call代读到的非应答消息，留给下一次Receive
+	calls     map[uint64]chan interface{}
+	callMu    sync.Mutex
 	sm        *SessionManager
 	closeChan chan int
+	closeReason error
+	opts      SessionOptions
+	dropCallback atomic.Value
+	interceptors []Interceptor
+	interceptMu  sync.RWMutex
+	attrs     map[interface{}]interface{}
+	attrMu    sync.RWMutex
+	ctx       context.Context
+	cancel    context.CancelFunc
 	closeCallBackHead *callbackList
 	closeMu  sync.Mutex
+	connectedAt time.Time
 }
 
 //以单链表记录关闭调用链
@@ -39,12 +61,31 @@ type callbackList struct {
 }
 
 func (sm *SessionManager) NewSession(codec protocol.Codec, sendChanSize int) *Session {
-	session := newSession(codec ,sendChanSize ,sm)
-	sm.Set(session)
-	sm.wg.Add(1)
+	return sm.NewSessionWithOptions(codec, SessionOptions{SendChanSize: sendChanSize})
+}
+
+func (sm *SessionManager) NewSessionWithOptions(codec protocol.Codec, opts SessionOptions) *Session {
+	session := newSession(codec ,opts ,sm)
+	sm.add(session)
 	return session
 }
 
+//Drain或Destroy开始后创建的Session直接关闭，不触发钩子，避免Destroy等待时wg又增加
+func (sm *SessionManager) add(session *Session) {
+	session.Use(sm.Interceptors()...)
+	sm.mu.Lock()
+	if sm.stopped {
+		sm.mu.Unlock()
+		session.sm = nil
+		session.Close()
+		return
+	}
+	sm.sessions[session.id] = session
+	sm.wg.Add(1)
+	sm.mu.Unlock()
+	sm.sessionCreated(session)
+}
+
 func (sm *SessionManager) Set(session *Session) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
@@ -52,6 +93,8 @@ func (sm *SessionManager) Set(session *Session) {
 }
 
 func (sm *SessionManager) Len() int64 {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
 	return int64(len(sm.sessions))
 }
 
@@ -76,6 +119,7 @@ func (sm *SessionManager) Del(sid int64) {
 func (sm *SessionManager) Destroy() {
 	sm.destroyOnce.Do(func(){
 		sm.mu.Lock()
+		sm.stopped = true
 		for _,session := range sm.sessions {
 			session.Close()
 		}
@@ -91,25 +135,42 @@ func NewSessionManager() *SessionManager {
 }
 
 func NewSession(codec protocol.Codec, sendChanSize int) *Session {
-	return newSession(codec,sendChanSize,nil)
+	return newSession(codec,SessionOptions{SendChanSize: sendChanSize},nil)
 }
 
-func newSession(codec protocol.Codec, sendChanSize int, sm *SessionManager) *Session {
+func newSession(codec protocol.Codec, opts SessionOptions, sm *SessionManager) *Session {
 	session := &Session{}
 	session.id = atomic.AddInt64(&sessionID,1)
 	session.closeChan = make(chan int)
-	if sendChanSize > 0 {
-		session.sendChan = make(chan interface{},sendChanSize)
-		go session.sendLoop()
-	}
+	session.recvLock = make(chan struct{}, 1)
+	session.calls = make(map[uint64]chan interface{})
 	session.closeFlag = 0
 	session.codec = codec
 	session.sm = sm
+	session.opts = opts
+	session.ctx, session.cancel = context.WithCancel(context.Background())
+	now := time.Now().UnixNano()
+	session.lastRead = now
+	session.lastWrite = now
+	session.connectedAt = time.Unix(0, now)
+	if opts.DropCallback != nil {
+		session.SetDropCallback(opts.DropCallback)
+	}
+	if opts.SendChanSize > 0 {
+		session.sendChan = make(chan interface{},opts.SendChanSize)
+		go session.sendLoop()
+	}
+	if opts.ReadIdle > 0 || opts.WriteIdle > 0 || opts.Idle > 0 || opts.Heartbeat > 0 {
+		go session.idleLoop(opts)
+	}
 	return session
 }
 
 var SessionClosedError = errors.New("Session Closed")
 var SessionBlockedError = errors.New("Session Blocked")
+var ReadIdleTimeoutError = errors.New("Session Read Idle Timeout")
+var WriteIdleTimeoutError = errors.New("Session Write Idle Timeout")
+var IdleTimeoutError = errors.New("Session Idle Timeout")
 
 func (s *Session) ID() int64 {
 	return s.id
@@ -185,18 +246,27 @@ func (s *Session) InvokeCallbackFun() {
 	}
 }
 
-func (s *Session) Receive() (interface{},error) {
-	s.recvMu.Lock()
-	defer s.recvMu.Unlock()
+func (s *Session) receiveMessage() (interface{},error) {
+	s.recvLock <- struct{}{}
+	defer func(){ <-s.recvLock }()
+
+	for {
+		if r, ok := s.popReceived(); ok {
+			return r.msg, r.err
+		}
 
-	msg,err := s.codec.Receive()
-	if err != nil {
-		return nil,err
+		msg,err := s.receive()
+		if err != nil {
+			return nil,err
+		}
+		//Call的应答在这里被消费掉，不交给handler
+		if msg, ok := s.dispatchReceived(msg); ok {
+			return msg,nil
+		}
 	}
-	return msg,nil
 }
 
-func (s *Session) Send(msg interface{}) error {
+func (s *Session) sendMessage(msg interface{}) error {
 	//异步send,先写buffer chan，利用读锁来提高并发能力
 	if s.sendChan != nil {
 		s.sendMu.RLock()
@@ -205,15 +275,12 @@ func (s *Session) Send(msg interface{}) error {
 			return SessionClosedError
 		}
 
-		select {
-		case s.sendChan <- msg :
-			s.sendMu.RUnlock()
-			return nil
-		default:
-			s.sendMu.RUnlock()
-			s.Close()
-			return SessionBlockedError
+		err := s.enqueue(msg)
+		s.sendMu.RUnlock()
+		if err == SessionBlockedError {
+			s.CloseWithReason(err)
 		}
+		return err
 	}
 
 	//同步send，利用写锁来确保原子性
@@ -223,7 +290,7 @@ func (s *Session) Send(msg interface{}) error {
 	s.sendMu.Lock()
 	defer s.sendMu.Unlock()
 
-	if err := s.codec.Send(msg); err != nil {
+	if err := s.send(msg); err != nil {
 		s.Close()
 		return err
 	}
@@ -231,11 +298,31 @@ func (s *Session) Send(msg interface{}) error {
 }
 
 func (s *Session) Close() error {
+	return s.CloseWithReason(nil)
+}
+
+//关闭Session并记录关闭原因，只有第一次关闭时的原因会被记录
+//与Close一样，codec关闭出错时返回该错误，否则返回SessionClosedError
+func (s *Session) CloseWithReason(reason error) error {
+	if closed, err := s.closeWithReason(reason); closed && err != nil {
+		return err
+	}
+	return SessionClosedError
+}
+
+//closed表示这次调用是否真正关闭了Session
+func (s *Session) closeWithReason(reason error) (closed bool, err error) {
 	if atomic.CompareAndSwapInt32(&s.closeFlag, 0, 1) {
+		s.closeReason = reason
 		close(s.closeChan)//关闭通道，让sendLoop goroutine 先退出
+		s.cancel()
 		if s.sendChan != nil {
 			s.sendMu.Lock()
-			s.clearSendChanBuff()//清除剩余的buff再关闭
+			if isIdleTimeout(reason) {
+				s.discardSendChanBuff()
+			} else {
+				s.clearSendChanBuff()//清除剩余的buff再关闭
+			}
 			close(s.sendChan)
 			s.sendMu.Unlock()
 		}
@@ -244,22 +331,32 @@ func (s *Session) Close() error {
 		if s.sm != nil {
 			go func(){
 				s.InvokeCallbackFun()
+				s.sm.sessionClosed(s, reason)
 				s.sm.Del(s.id)
 			}()
 		}
 
-		if err != nil {
-			return err
-		}
+		return true, err
+	}
+	return false, nil
+}
+
+//空闲超时关闭时对端可能已经不再读取，刷出剩余的消息会一直阻塞在写上，直接丢弃
+func isIdleTimeout(reason error) bool {
+	return reason == ReadIdleTimeoutError || reason == WriteIdleTimeoutError || reason == IdleTimeoutError
+}
+
+func (s *Session) discardSendChanBuff() {
+	for l := len(s.sendChan); l > 0; l-- {
+		<-s.sendChan
 	}
-	return SessionClosedError
 }
 
 func (s *Session) clearSendChanBuff() error {
 	l := len(s.sendChan)
 	for i:=0; i<l;i++ {
 		msg := <-s.sendChan
-		if err := s.codec.Send(msg); err != nil {
+		if err := s.send(msg); err != nil {
 			return err
 		}
 	}
@@ -274,7 +371,7 @@ func (s *Session) sendLoop() {
 			if !ok {//通道关闭
 				return
 			}
-			if err := s.codec.Send(msg); err != nil {
+			if err := s.send(msg); err != nil {
 				return
 			}
 		case <- s.closeChan:
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"time"
+)
+
+//tcp连接的socket选项，零值表示全部保持系统默认
+type SocketOptions struct {
+	NoDelay     *bool         //TCP_NODELAY，nil表示保持默认(Go默认开启)
+	KeepAlive   time.Duration //keepalive探测间隔，0表示保持默认，小于0表示关闭keepalive
+	ReadBuffer  int           //SO_RCVBUF字节数，0表示保持默认
+	WriteBuffer int           //SO_SNDBUF字节数，0表示保持默认
+	Linger      *int          //SO_LINGER秒数，nil表示保持默认，0表示Close时直接丢弃未发出的数据
+}
+
+//从server/client的配置中解析socket选项：
+//noDelay "true"/"false"
+//keepAlive keepalive探测间隔，如"30s"，"0"表示关闭
+//readBuffer,writeBuffer 内核收发缓冲区字节数
+//linger Close后等待未发出数据的秒数
+func ParseSocketOptions(cfg map[string]string) (SocketOptions, error) {
+	var opts SocketOptions
+	if v, ok := cfg["noDelay"]; ok {
+		noDelay, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("Socket:noDelay %q is invalid", v)
+		}
+		opts.NoDelay = &noDelay
+	}
+	if v, ok := cfg["keepAlive"]; ok {
+		d, err := time.ParseDuration(v)
+		if v == "0" {
+			d, err = -1, nil
+		}
+		if err != nil || d == 0 {
+			return opts, fmt.Errorf("Socket:keepAlive %q is invalid", v)
+		}
+		opts.KeepAlive = d
+	}
+	ints := []struct {
+		key string
+		val *int
+	}{
+		{"readBuffer", &opts.ReadBuffer},
+		{"writeBuffer", &opts.WriteBuffer},
+	}
+	for _, i := range ints {
+		if v, ok := cfg[i.key]; ok {
+			var err error
+			if *i.val, err = strconv.Atoi(v); err != nil || *i.val <= 0 {
+				return opts, fmt.Errorf("Socket:%s %q is invalid", i.key, v)
+			}
+		}
+	}
+	if v, ok := cfg["linger"]; ok {
+		linger, err := strconv.Atoi(v)
+		if err != nil || linger < 0 {
+			return opts, fmt.Errorf("Socket:linger %q is invalid", v)
+		}
+		opts.Linger = &linger
+	}
+	return opts, nil
+}
+
+//把选项设置到conn上，conn不是*net.TCPConn时什么也不做
+func (o SocketOptions) Apply(conn net.Conn) error {
+	tc, ok := conn.(*net.TCPConn)
+	if !ok {
+		return nil
+	}
+	if o.NoDelay != nil {
+		if err := tc.SetNoDelay(*o.NoDelay); err != nil {
+			return err
+		}
+	}
+	if o.KeepAlive < 0 {
+		if err := tc.SetKeepAlive(false); err != nil {
+			return err
+		}
+	} else if o.KeepAlive > 0 {
+		if err := tc.SetKeepAlive(true); err != nil {
+			return err
+		}
+		if err := tc.SetKeepAlivePeriod(o.KeepAlive); err != nil {
+			return err
+		}
+	}
+	if o.ReadBuffer > 0 {
+		if err := tc.SetReadBuffer(o.ReadBuffer); err != nil {
+			return err
+		}
+	}
+	if o.WriteBuffer > 0 {
+		if err := tc.SetWriteBuffer(o.WriteBuffer); err != nil {
+			return err
+		}
+	}
+	if o.Linger != nil {
+		if err := tc.SetLinger(*o.Linger); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//包装listener，Accept到的每个连接都设置选项，应在PROXY和TLS之前包装
+func (o SocketOptions) Listener(l net.Listener) net.Listener {
+	if o == (SocketOptions{}) {
+		return l
+	}
+	return &sockoptListener{Listener: l, opts: o}
+}
+
+type sockoptListener struct {
+	net.Listener
+	opts SocketOptions
+}
+
+//设置失败不影响连接的使用，只记录日志
+func (l *sockoptListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if err := l.opts.Apply(conn); err != nil {
+		log.Printf("Set socket options on %s err:%v\n", conn.RemoteAddr(), err)
+	}
+	return conn, nil
+}
@@ -0,0 +1,192 @@
+package sseserver
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+var ErrResumeTimeout = errors.New("sse: client did not reconnect in time")
+var errConnClosed = errors.New("sse: use of closed connection")
+
+type event struct {
+	id   uint64
+	data []byte
+}
+
+//一个token对应的虚拟连接：POST上来的body依次作为读取的字节流，
+//每次Write作为一个带递增id的事件，保留最近的replaySize个事件供断线重连后补发
+type sseConn struct {
+	token      string
+	replaySize int
+	local      net.Addr
+	remote     net.Addr
+
+	mu       sync.Mutex
+	in       bytes.Buffer
+	events   []event
+	nextID   uint64
+	wake     chan struct{} //有新事件或连接关闭时关闭并替换
+	attached int           //正在读取事件的stream/poll请求数
+	idle     *time.Timer
+	closed   bool
+
+	readable  chan struct{}
+	closeChan chan struct{}
+	closeOnce sync.Once
+	onClose   func()
+}
+
+func newSSEConn(token string, replaySize int, onClose func()) *sseConn {
+	c := &sseConn{}
+	c.token = token
+	c.replaySize = replaySize
+	c.wake = make(chan struct{})
+	c.readable = make(chan struct{}, 1)
+	c.closeChan = make(chan struct{})
+	c.onClose = onClose
+	return c
+}
+
+func (c *sseConn) Read(p []byte) (int, error) {
+	for {
+		c.mu.Lock()
+		if c.in.Len() > 0 {
+			n, _ := c.in.Read(p)
+			c.mu.Unlock()
+			return n, nil
+		}
+		closed := c.closed
+		c.mu.Unlock()
+		if closed {
+			return 0, io.EOF
+		}
+
+		select {
+		case <-c.readable:
+		case <-c.closeChan:
+		}
+	}
+}
+
+//客户端POST上来的数据
+func (c *sseConn) feed(data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return errConnClosed
+	}
+	c.in.Write(data)
+	select {
+	case c.readable <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (c *sseConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return 0, errConnClosed
+	}
+	c.nextID++
+	c.events = append(c.events, event{id: c.nextID, data: append([]byte(nil), p...)})
+	if len(c.events) > c.replaySize {
+		c.events = c.events[len(c.events)-c.replaySize:]
+	}
+	close(c.wake)
+	c.wake = make(chan struct{})
+	return len(p), nil
+}
+
+func (c *sseConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.mu.Lock()
+		c.closed = true
+		close(c.wake)
+		if c.idle != nil {
+			c.idle.Stop()
+		}
+		c.mu.Unlock()
+		close(c.closeChan)
+		c.onClose()
+	})
+	return nil
+}
+
+func (c *sseConn) lastID() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.nextID
+}
+
+//返回id大于last的事件，没有时等待新事件、连接关闭、done或timeout
+//gone为true表示last之后的事件已经被丢弃，无法续传
+func (c *sseConn) eventsAfter(last uint64, done <-chan struct{}, timeout <-chan time.Time) (events []event, closed, gone bool) {
+	for {
+		c.mu.Lock()
+		if len(c.events) > 0 && c.events[0].id > last+1 {
+			c.mu.Unlock()
+			return nil, false, true
+		}
+		for _, e := range c.events {
+			if e.id > last {
+				events = append(events, e)
+			}
+		}
+		closed = c.closed
+		wake := c.wake
+		c.mu.Unlock()
+
+		if len(events) > 0 || closed {
+			return events, closed, false
+		}
+		select {
+		case <-wake:
+		case <-done:
+			return nil, false, false
+		case <-timeout:
+			return nil, false, false
+		}
+	}
+}
+
+//没有stream/poll请求时开始计时，超过resumeTimeout还没有重连则调用expire
+func (c *sseConn) attach() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.attached++
+	if c.idle != nil {
+		c.idle.Stop()
+		c.idle = nil
+	}
+}
+
+func (c *sseConn) detach(resumeTimeout time.Duration, expire func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.attached--
+	if c.attached > 0 || c.closed {
+		return
+	}
+	c.idle = time.AfterFunc(resumeTimeout, func() {
+		c.mu.Lock()
+		attached := c.attached
+		c.mu.Unlock()
+		if attached == 0 {
+			expire()
+		}
+	})
+}
+
+func (c *sseConn) LocalAddr() net.Addr {
+	return c.local
+}
+
+func (c *sseConn) RemoteAddr() net.Addr {
+	return c.remote
+}
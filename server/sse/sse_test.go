@@ -0,0 +1,243 @@
+package sseserver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gary163/seals/protocol"
+	_ "github.com/gary163/seals/protocol/binary"
+	"github.com/gary163/seals/server"
+)
+
+type sseEvent struct {
+	name string
+	id   string
+	data string
+}
+
+//按SSE格式逐个读取事件
+func readEvents(r io.Reader, events chan<- sseEvent) {
+	defer close(events)
+	scanner := bufio.NewScanner(r)
+	var e sseEvent
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if data != nil {
+				e.data = strings.Join(data, "\n")
+				events <- e
+			}
+			e, data = sseEvent{}, nil
+		case strings.HasPrefix(line, "event: "):
+			e.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "id: "):
+			e.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			data = append(data, strings.TrimPrefix(line, "data: "))
+		}
+	}
+}
+
+type testClient struct {
+	t       *testing.T
+	client  *http.Client
+	proto   protocol.Protocol
+	baseURL string
+	token   string
+	lastID  string
+}
+
+func (c *testClient) stream(ctx context.Context) <-chan sseEvent {
+	url := c.baseURL
+	if c.token != "" {
+		url += "?token=" + c.token
+	}
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req = req.WithContext(ctx)
+	if c.lastID != "" {
+		req.Header.Set("Last-Event-ID", c.lastID)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		c.t.Fatalf("Stream err:%v\n", err)
+	}
+	events := make(chan sseEvent, 16)
+	go func() {
+		defer resp.Body.Close()
+		readEvents(resp.Body, events)
+	}()
+	return events
+}
+
+func (c *testClient) send(msg string) {
+	var body bytes.Buffer
+	codec, _ := c.proto.NewCodec(&body)
+	codec.Send([]byte(msg))
+	resp, err := c.client.Post(c.baseURL+"/send?token="+c.token, "application/octet-stream", &body)
+	if err != nil {
+		c.t.Fatalf("Send err:%v\n", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		c.t.Fatalf("Send status %d\n", resp.StatusCode)
+	}
+}
+
+//读取事件直到解码出一条完整的消息
+func (c *testClient) receive(events <-chan sseEvent) string {
+	var stream bytes.Buffer
+	codec, _ := c.proto.NewCodec(&stream)
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				c.t.Fatal("event stream closed")
+			}
+			data, err := base64.StdEncoding.DecodeString(e.data)
+			if err != nil {
+				c.t.Fatalf("event %+v is not base64\n", e)
+			}
+			stream.Write(data)
+			c.lastID = e.id
+			if msg, err := codec.Receive(); err == nil {
+				return string(msg.([]byte))
+			}
+		case <-time.After(time.Second):
+			c.t.Fatal("no event received")
+		}
+	}
+}
+
+func TestSSEResume(t *testing.T) {
+	proto, err := protocol.NewProtocol("binary", `{"fixlen":{}}`)
+	if err != nil {
+		t.Fatalf("New protocol err:%v\n", err)
+	}
+
+	sm := server.NewSessionManager()
+	closed := make(chan error, 1)
+	sm.OnSessionClosed(func(session *server.Session, reason error) {
+		closed <- reason
+	})
+	srv, err := server.NewServerWithManager("sseServer", `{"addr":"127.0.0.1:0","resumeTimeout":"300ms"}`, proto, server.HandlerFunc(func(session *server.Session) {
+		defer session.Close()
+		for {
+			msg, err := session.Receive()
+			if err != nil {
+				return
+			}
+			session.Send(msg)
+		}
+	}), sm)
+	if err != nil {
+		t.Fatalf("New server err:%v\n", err)
+	}
+	go srv.Run()
+	defer srv.Stop()
+
+	baseURL := "http://" + srv.(*sseServer).listener.Addr().String() + "/sse"
+	c := &testClient{t: t, client: &http.Client{Transport: &http.Transport{}}, proto: proto, baseURL: baseURL}
+	ctx, cancel := context.WithCancel(context.Background())
+	events := c.stream(ctx)
+	open := <-events
+	if open.name != "open" || open.data == "" {
+		t.Fatalf("first event %+v, want open\n", open)
+	}
+	c.token = open.data
+
+	c.send("hello")
+	if got := c.receive(events); got != "hello" {
+		t.Fatalf("recv %q, want hello\n", got)
+	}
+
+	//断开期间的消息在重连后补发，仍然是同一个Session
+	cancel()
+	c.send("again")
+	ctx, cancel = context.WithCancel(context.Background())
+	events = c.stream(ctx)
+	if got := c.receive(events); got != "again" {
+		t.Fatalf("recv %q after resume, want again\n", got)
+	}
+	cancel()
+
+	//长轮询
+	c.send("poll")
+	resp, err := c.client.Get(baseURL + "/poll?token=" + c.token + "&lastEventId=" + c.lastID)
+	if err != nil {
+		t.Fatalf("Poll err:%v\n", err)
+	}
+	polled := make(chan sseEvent, 16)
+	readEvents(resp.Body, polled)
+	resp.Body.Close()
+	if got := c.receive(polled); got != "poll" {
+		t.Fatalf("poll %q, want poll\n", got)
+	}
+	if sm.Len() != 1 {
+		t.Fatalf("%d sessions, want 1\n", sm.Len())
+	}
+
+	//超过resumeTimeout没有重连则关闭Session
+	select {
+	case reason := <-closed:
+		if reason != ErrResumeTimeout {
+			t.Fatalf("close reason %v, want ErrResumeTimeout\n", reason)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("session not expired")
+	}
+	resp, err = c.client.Post(baseURL+"/send?token="+c.token, "application/octet-stream", strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("send to expired session status %d, want 404\n", resp.StatusCode)
+	}
+}
+
+//Stop等handler退出后才返回
+func TestSSEStopWaitsHandlers(t *testing.T) {
+	proto, err := protocol.NewProtocol("binary", `{"fixlen":{}}`)
+	if err != nil {
+		t.Fatalf("New protocol err:%v\n", err)
+	}
+
+	var exited int32
+	started := make(chan struct{})
+	srv, err := server.NewServer("sseServer", `{"addr":"127.0.0.1:0"}`, proto, server.HandlerFunc(func(session *server.Session) {
+		close(started)
+		for {
+			if _, err := session.Receive(); err != nil {
+				break
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+		atomic.StoreInt32(&exited, 1)
+	}))
+	if err != nil {
+		t.Fatalf("New server err:%v\n", err)
+	}
+	go srv.Run()
+
+	baseURL := "http://" + srv.(*sseServer).listener.Addr().String() + "/sse"
+	c := &testClient{t: t, client: &http.Client{Transport: &http.Transport{}}, proto: proto, baseURL: baseURL}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.stream(ctx)
+	<-started
+
+	srv.Stop()
+	if atomic.LoadInt32(&exited) != 1 {
+		t.Fatal("Stop returned before the handler exited")
+	}
+}
@@ -0,0 +1,440 @@
+package sseserver
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gary163/seals/protocol"
+	"github.com/gary163/seals/server"
+)
+
+const (
+	defaultMaxConn = 200000
+	defaultSendChanSize = 1024
+	defaultAddr = "0.0.0.0:0"
+	defaultPath = "/sse"
+	defaultHttpTimeout = 5
+	defaultMaxBodySize = 1<<20
+	defaultReplaySize = 256
+	defaultResumeTimeout = 30*time.Second
+	defaultKeepAlive = 15*time.Second
+	defaultPollTimeout = 25*time.Second
+)
+
+//不能使用websocket时的替代方案，一个token对应一个长期存在的Session：
+//GET  path          SSE事件流，服务端Send的数据作为事件推送
+//GET  path/poll     长轮询，返回一批事件，格式和事件流相同
+//POST path/send     body为protocol编码后的消息，交给Session读取
+//不带token的GET会创建新Session，第一个事件"open"的data为token；
+//带上token和Last-Event-ID(或lastEventId参数)重连时继续使用原来的Session并补发之后的事件
+//事件data默认是base64编码的字节流片段，encoding为text时原样发送，适合json等文本协议
+type sseServer struct {
+	addr          string
+	path          string
+	maxConn       int//最大Session数
+	maxBodySize   int64
+	replaySize    int
+	resumeTimeout time.Duration//断开后等待重连的时间，超时关闭Session
+	keepAlive     time.Duration//事件流上发送注释行的间隔，防止代理断开空闲连接
+	pollTimeout   time.Duration
+	textEncoding  bool
+	sessionOpts   server.SessionOptions
+	listener      net.Listener
+	httpServer    *http.Server
+	protocol      protocol.Protocol
+	handler       server.Handler
+	sm            *server.SessionManager
+	conns         map[string]*sseConn
+	sessions      map[string]*server.Session
+	connsMu       sync.Mutex
+	handlers      server.HandlerGroup
+}
+
+type tokenKey struct{}
+
+func init() {
+	server.RegisterServer("sseServer",&sseServer{})
+}
+
+func (s *sseServer) Init(config string, protocol protocol.Protocol, handler server.Handler, sm *server.SessionManager) error {
+	var cfg map[string]string
+	err := json.Unmarshal([]byte(config),&cfg)
+	if err != nil {
+		return err
+	}
+
+	if _,ok := cfg["maxConn"]; !ok {
+		cfg["maxConn"] = strconv.Itoa(defaultMaxConn)
+	}
+
+	if _,ok := cfg["sendChanSize"]; !ok {
+		cfg["sendChanSize"] = strconv.Itoa(defaultSendChanSize)
+	}
+
+	if _,ok := cfg["addr"]; !ok {
+		cfg["addr"] = defaultAddr
+	}
+
+	if _,ok := cfg["path"]; !ok {
+		cfg["path"] = defaultPath
+	}
+
+	if _,ok := cfg["httpTimeout"]; !ok {
+		cfg["httpTimeout"] = strconv.Itoa(int(defaultHttpTimeout * time.Second))
+	}
+
+	if _,ok := cfg["maxBodySize"]; !ok {
+		cfg["maxBodySize"] = strconv.Itoa(defaultMaxBodySize)
+	}
+
+	if _,ok := cfg["replaySize"]; !ok {
+		cfg["replaySize"] = strconv.Itoa(defaultReplaySize)
+	}
+
+	switch cfg["encoding"] {
+	case "", "base64":
+		s.textEncoding = false
+	case "text":
+		s.textEncoding = true
+	default:
+		return errors.New("SSEServer:encoding must be base64 or text")
+	}
+
+	durations := []struct {
+		key string
+		val *time.Duration
+		def time.Duration
+	}{
+		{"resumeTimeout", &s.resumeTimeout, defaultResumeTimeout},
+		{"keepAlive", &s.keepAlive, defaultKeepAlive},
+		{"pollTimeout", &s.pollTimeout, defaultPollTimeout},
+	}
+	for _,d := range durations {
+		*d.val = d.def
+		if v,ok := cfg[d.key]; ok {
+			if *d.val,err = time.ParseDuration(v); err != nil {
+				return fmt.Errorf("SSEServer:%s %q is invalid", d.key, v)
+			}
+		}
+	}
+
+	s.maxConn,_     = strconv.Atoi(cfg["maxConn"])
+	s.maxBodySize,_ = strconv.ParseInt(cfg["maxBodySize"], 10, 64)
+	s.replaySize,_  = strconv.Atoi(cfg["replaySize"])
+	s.addr          = cfg["addr"]
+	s.path          = strings.TrimSuffix(cfg["path"], "/")
+	httpTimeout,_   := strconv.Atoi(cfg["httpTimeout"])
+	if s.sessionOpts,err = server.ParseSessionOptions(cfg); err != nil {
+		return err
+	}
+	s.protocol = protocol
+	s.handler  = handler
+	s.sm       = sm
+	s.conns    = make(map[string]*sseConn)
+	s.sessions = make(map[string]*server.Session)
+
+	tlsConfig,err := server.ParseServerTLSConfig(cfg)
+	if err != nil {
+		return err
+	}
+	//"reusePort":"true"时多个进程可以监听同一端口，热重启时使用父进程传下来的socket
+	reusePort,_ := strconv.ParseBool(cfg["reusePort"])
+	if s.listener,err = server.Listen("tcp", s.addr, reusePort); err != nil {
+		return err
+	}
+	if tlsConfig != nil {
+		s.listener = tls.NewListener(s.listener, tlsConfig)
+	}
+
+	mux := http.NewServeMux()
+	if s.path == "" {
+		mux.HandleFunc("/", s.serveStream)
+	} else {
+		mux.HandleFunc(s.path, s.serveStream)
+	}
+	mux.HandleFunc(s.path+"/poll", s.servePoll)
+	mux.HandleFunc(s.path+"/send", s.serveSend)
+	//事件流是长连接，不能设置WriteTimeout
+	s.httpServer = &http.Server{
+		Addr:        s.addr,
+		Handler:     mux,
+		ReadTimeout: time.Duration(httpTimeout),
+	}
+	return nil
+}
+
+func (s *sseServer) Run() error {
+	err := s.httpServer.Serve(s.listener)
+	if err == http.ErrServerClosed {
+		return io.EOF
+	}
+	return err
+}
+
+func newToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+//创建新的Session，在单独的goroutine中交给handler处理
+func (s *sseServer) open(r *http.Request) (*sseConn, error) {
+	if s.sm.Len() >= int64(s.maxConn) {
+		return nil, fmt.Errorf("too many connection:%d", s.maxConn)
+	}
+	//Stop已经开始等待handler，不再创建新的Session
+	if !s.handlers.Add() {
+		return nil, errors.New("server stopping")
+	}
+
+	token := newToken()
+	conn := newSSEConn(token, s.replaySize, func() {
+		s.connsMu.Lock()
+		delete(s.conns, token)
+		delete(s.sessions, token)
+		s.connsMu.Unlock()
+	})
+	if addr,ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		conn.local = addr
+	}
+	if addr,err := net.ResolveTCPAddr("tcp", r.RemoteAddr); err == nil {
+		conn.remote = addr
+	}
+
+	codec,_ := s.protocol.NewCodec(conn)
+	session := s.sm.NewSessionWithInfo(codec, s.sessionOpts, server.ConnInfo{
+		Transport:  "sse",
+		LocalAddr:  conn.LocalAddr(),
+		RemoteAddr: conn.RemoteAddr(),
+		TLS:        r.TLS,
+		Attrs:      map[interface{}]interface{}{tokenKey{}: token},
+	})
+
+	s.connsMu.Lock()
+	s.conns[token] = conn
+	s.sessions[token] = session
+	s.connsMu.Unlock()
+
+	go func(){
+		defer s.handlers.Done()
+		s.handler.Handle(session)
+	}()
+	return conn, nil
+}
+
+func (s *sseServer) lookup(token string) (*sseConn, *server.Session, bool) {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+	conn,ok := s.conns[token]
+	return conn, s.sessions[token], ok
+}
+
+//GET请求公共的部分：找到或创建连接，解析续传的位置
+func (s *sseServer) attach(w http.ResponseWriter, r *http.Request) (conn *sseConn, last uint64, created bool) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil, 0, false
+	}
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		var err error
+		if conn,err = s.open(r); err != nil {
+			log.Printf("SSEServer:%v\n",err)
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return nil, 0, false
+		}
+		conn.attach()
+		return conn, 0, true
+	}
+
+	conn,_,ok := s.lookup(token)
+	if !ok {
+		http.Error(w, "unknown token", http.StatusNotFound)
+		return nil, 0, false
+	}
+	//没有指定位置时只接收之后的新事件
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("lastEventId")
+	}
+	if lastID == "" {
+		last = conn.lastID()
+	} else if id,err := strconv.ParseUint(lastID, 10, 64); err == nil {
+		last = id
+	} else {
+		http.Error(w, "invalid last event id", http.StatusBadRequest)
+		return nil, 0, false
+	}
+	conn.attach()
+	return conn, last, false
+}
+
+func (s *sseServer) detach(conn *sseConn) {
+	conn.detach(s.resumeTimeout, func() {
+		if _,session,ok := s.lookup(conn.token); ok {
+			session.CloseWithReason(ErrResumeTimeout)
+		}
+	})
+}
+
+func (s *sseServer) serveStream(w http.ResponseWriter, r *http.Request) {
+	conn,last,created := s.attach(w, r)
+	if conn == nil {
+		return
+	}
+	defer s.detach(conn)
+
+	flusher,ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	s.writeHeader(w)
+	bw := bufio.NewWriter(w)
+	if created {
+		writeEvent(bw, "open", "", []byte(conn.token))
+	}
+
+	for {
+		bw.Flush()
+		flusher.Flush()
+
+		timer := time.NewTimer(s.keepAlive)
+		events,closed,gone := conn.eventsAfter(last, r.Context().Done(), timer.C)
+		timer.Stop()
+		if gone {
+			writeEvent(bw, "gone", "", nil)
+			bw.Flush()
+			return
+		}
+		for _,e := range events {
+			s.writeData(bw, e)
+			last = e.id
+		}
+		if closed {
+			writeEvent(bw, "close", "", nil)
+			bw.Flush()
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		default:
+		}
+		if len(events) == 0 {
+			bw.WriteString(": ping\n\n")
+		}
+	}
+}
+
+func (s *sseServer) servePoll(w http.ResponseWriter, r *http.Request) {
+	conn,last,created := s.attach(w, r)
+	if conn == nil {
+		return
+	}
+	defer s.detach(conn)
+
+	s.writeHeader(w)
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+	if created {
+		writeEvent(bw, "open", "", []byte(conn.token))
+		return
+	}
+
+	timer := time.NewTimer(s.pollTimeout)
+	defer timer.Stop()
+	events,closed,gone := conn.eventsAfter(last, r.Context().Done(), timer.C)
+	if gone {
+		writeEvent(bw, "gone", "", nil)
+		return
+	}
+	for _,e := range events {
+		s.writeData(bw, e)
+	}
+	if closed {
+		writeEvent(bw, "close", "", nil)
+	}
+}
+
+func (s *sseServer) serveSend(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	conn,_,ok := s.lookup(r.URL.Query().Get("token"))
+	if !ok {
+		http.Error(w, "unknown token", http.StatusNotFound)
+		return
+	}
+	body,err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, s.maxBodySize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err = conn.feed(body); err != nil {
+		http.Error(w, err.Error(), http.StatusGone)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *sseServer) writeHeader(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *sseServer) writeData(w *bufio.Writer, e event) {
+	data := e.data
+	if !s.textEncoding {
+		data = []byte(base64.StdEncoding.EncodeToString(data))
+	}
+	writeEvent(w, "", strconv.FormatUint(e.id, 10), data)
+}
+
+//data中的换行拆成多个data行，客户端会用换行重新拼接
+func writeEvent(w *bufio.Writer, name, id string, data []byte) {
+	if name != "" {
+		w.WriteString("event: "+name+"\n")
+	}
+	if id != "" {
+		w.WriteString("id: "+id+"\n")
+	}
+	for _,line := range strings.Split(string(data), "\n") {
+		w.WriteString("data: "+line+"\n")
+	}
+	w.WriteString("\n")
+}
+
+//sseServer创建的Session对应的token
+func Token(session *server.Session) (string, bool) {
+	return session.AttrString(tokenKey{})
+}
+
+//Session都关闭后等待handler退出再返回
+func (s *sseServer) Stop() error {
+	s.httpServer.Close()
+	s.sm.Destroy()
+	return s.handlers.Wait(context.Background())
+}
@@ -0,0 +1,95 @@
+package tcpserver
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gary163/seals/protocol"
+	"github.com/gary163/seals/server"
+)
+
+func TestBroadcast(t *testing.T) {
+	const connNum = 5
+	proto, err := protocol.NewProtocol("binary", `{"fixlen":{}}`)
+	if err != nil {
+		t.Fatalf("New protocol err:%v\n", err)
+	}
+
+	group := server.NewChannel()
+	var joined sync.WaitGroup
+	joined.Add(connNum)
+	srv, err := server.NewServer("tcpServer", `{"addr":"127.0.0.1:0"}`, proto, server.HandlerFunc(func(session *server.Session) {
+		defer session.Close()
+		group.Set(session.ID(), session)
+		joined.Done()
+		for {
+			if _, err := session.Receive(); err != nil {
+				return
+			}
+		}
+	}))
+	if err != nil {
+		t.Fatalf("New server err:%v\n", err)
+	}
+	go srv.Run()
+	defer srv.Stop()
+
+	msg := []byte("broadcast")
+	var received sync.WaitGroup
+	received.Add(connNum - 1)
+	cli, err := server.NewClient("tcpClient", `{"addr":"`+serverAddr(srv)+`","connNum":"5"}`, proto, server.HandlerFunc(func(session *server.Session) {
+		defer session.Close()
+		for i := 0; ; i++ {
+			recv, err := session.Receive()
+			if err != nil {
+				return
+			}
+			if !bytes.Equal(recv.([]byte), msg) {
+				t.Errorf("recv %q, want %q\n", recv, msg)
+			}
+			if i == 0 {
+				received.Done()
+			}
+		}
+	}))
+	if err != nil {
+		t.Fatalf("New client err:%v\n", err)
+	}
+	go cli.Run()
+	defer cli.Close()
+	joined.Wait()
+
+	var excluded *server.Session
+	group.Fetch(func(session *server.Session) {
+		excluded = session
+	})
+	report := group.Broadcast(msg, &server.BroadcastOptions{Workers: 2, Exclude: []int64{excluded.ID()}})
+	if report.Total != connNum-1 || report.Sent != connNum-1 || len(report.Failed) != 0 {
+		t.Fatalf("unexpected report %+v\n", report)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		received.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("broadcast not received")
+	}
+
+	//关闭回调是异步的，已关闭的Session可能还在channel中，此时应出现在Failed里
+	excluded.Close()
+	report = group.Broadcast(msg, nil)
+	for id, err := range report.Failed {
+		if id != excluded.ID() || err != server.SessionClosedError {
+			t.Fatalf("unexpected failure session:%d err:%v\n", id, err)
+		}
+	}
+	if report.Sent != connNum-1 {
+		t.Fatalf("unexpected report %+v\n", report)
+	}
+}
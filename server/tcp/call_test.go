@@ -0,0 +1,109 @@
+package tcpserver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gary163/seals/protocol"
+	"github.com/gary163/seals/server"
+)
+
+type callAdd struct {
+	A, B int
+}
+
+type callSum struct {
+	Sum int
+}
+
+type callStart struct {
+}
+
+type callResult struct {
+	Sum int
+	Err string
+}
+
+type callIgnored struct {
+}
+
+func TestSessionCall(t *testing.T) {
+	proto, err := protocol.NewProtocol("json", `{"fixlen":{}}`)
+	if err != nil {
+		t.Fatalf("New protocol err:%v\n", err)
+	}
+	proto.Register(&callAdd{})
+	proto.Register(&callSum{})
+	proto.Register(&callStart{})
+	proto.Register(&callResult{})
+	proto.Register(&callIgnored{})
+
+	router := server.NewRouter(proto)
+	router.Route(&callAdd{}, func(session *server.Session, msg interface{}) {
+		req := msg.(*server.Request)
+		add := req.Body.(*callAdd)
+		req.Reply(&callSum{add.A + add.B})
+	})
+	router.Route(&callStart{}, func(session *server.Session, msg interface{}) {
+		//服务端主动发起Call
+		go func() {
+			result := &callResult{}
+			reply, err := session.Call(&callAdd{10, 20}, time.Second)
+			if err != nil {
+				result.Err = err.Error()
+			} else {
+				result.Sum = reply.(*callSum).Sum
+			}
+			session.Send(result)
+		}()
+	})
+	router.Route(&callIgnored{}, func(session *server.Session, msg interface{}) {})
+
+	srv, err := server.NewServer("tcpServer", `{"addr":"127.0.0.1:0"}`, proto, router)
+	if err != nil {
+		t.Fatalf("New server err:%v\n", err)
+	}
+	go srv.Run()
+	defer srv.Stop()
+
+	cli, err := server.NewClient("tcpClient", `{"addr":"`+serverAddr(srv)+`"}`, proto, server.HandlerFunc(func(session *server.Session) {
+		defer session.Close()
+
+		//没有Receive循环时，Call自己读取应答
+		reply, err := session.Call(&callAdd{1, 2}, time.Second)
+		if err != nil {
+			t.Errorf("Call err:%v\n", err)
+			return
+		}
+		if sum := reply.(*callSum).Sum; sum != 3 {
+			t.Errorf("Call reply sum %d, want 3\n", sum)
+		}
+
+		if _, err := session.Call(&callIgnored{}, 50*time.Millisecond); err != server.CallTimeoutError {
+			t.Errorf("Call err %v, want CallTimeoutError\n", err)
+		}
+
+		session.Send(&callStart{})
+		for {
+			msg, err := session.Receive()
+			if err != nil {
+				t.Errorf("Receive err:%v\n", err)
+				return
+			}
+			switch msg := msg.(type) {
+			case *server.Request:
+				add := msg.Body.(*callAdd)
+				msg.Reply(&callSum{add.A + add.B})
+			case *callResult:
+				if msg.Err != "" || msg.Sum != 30 {
+					t.Errorf("server Call result %+v, want sum 30\n", msg)
+				}
+				return
+			}
+		}
+	}))
+	if err != nil {
+		t.Fatalf("New client err:%v\n", err)
+	}
+	cli.Run()
+}
@@ -0,0 +1,82 @@
+package tcpserver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gary163/seals/protocol"
+	"github.com/gary163/seals/server"
+)
+
+type goingAway struct {
+	Reason string
+}
+
+func gracefulTest(t *testing.T, leaveOnGoingAway bool, wantCut int) {
+	proto, err := protocol.NewProtocol("json", `{"fixlen":{}}`)
+	if err != nil {
+		t.Fatalf("New protocol err:%v\n", err)
+	}
+	proto.Register(&goingAway{})
+
+	connected := make(chan struct{})
+	srv, err := server.NewServer("tcpServer", `{"addr":"127.0.0.1:0"}`, proto, server.HandlerFunc(func(session *server.Session) {
+		defer session.Close()
+		connected <- struct{}{}
+		for {
+			if _, err := session.Receive(); err != nil {
+				return
+			}
+		}
+	}))
+	if err != nil {
+		t.Fatalf("New server err:%v\n", err)
+	}
+	graceful := srv.(server.GracefulServer)
+	graceful.SetGoingAway(&goingAway{"restart"})
+	go srv.Run()
+
+	cli, err := server.NewClient("tcpClient", `{"addr":"`+serverAddr(srv)+`"}`, proto, server.HandlerFunc(func(session *server.Session) {
+		defer session.Close()
+		for {
+			msg, err := session.Receive()
+			if err != nil {
+				return
+			}
+			if _, ok := msg.(*goingAway); ok && leaveOnGoingAway {
+				return
+			}
+		}
+	}))
+	if err != nil {
+		t.Fatalf("New client err:%v\n", err)
+	}
+	clientDone := make(chan struct{})
+	go func() {
+		cli.Run()
+		close(clientDone)
+	}()
+	<-connected
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	cut, err := graceful.StopGracefully(ctx)
+	if cut != wantCut {
+		t.Fatalf("StopGracefully cut %d sessions, want %d\n", cut, wantCut)
+	}
+	//强制关闭后handler立即退出，不应报告超时
+	if err != nil {
+		t.Fatalf("StopGracefully err:%v\n", err)
+	}
+	graceful.SetGoingAway(nil)
+	<-clientDone
+}
+
+func TestStopGracefully(t *testing.T) {
+	gracefulTest(t, true, 0)
+}
+
+func TestStopGracefullyTimeout(t *testing.T) {
+	gracefulTest(t, false, 1)
+}
@@ -0,0 +1,96 @@
+package tcpserver
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gary163/seals/protocol"
+	"github.com/gary163/seals/server"
+	_ "github.com/gary163/seals/server/mem"
+)
+
+func TestGroup(t *testing.T) {
+	proto, err := protocol.NewProtocol("binary", `{"fixlen":{}}`)
+	if err != nil {
+		t.Fatalf("New protocol err:%v\n", err)
+	}
+
+	//tcp和mem两个listener上的Session在同一个channel中
+	members := server.NewChannel()
+	var joined sync.WaitGroup
+	joined.Add(2)
+	group := server.NewGroup(server.HandlerFunc(func(session *server.Session) {
+		defer session.Close()
+		if _, err := session.Receive(); err != nil {
+			return
+		}
+		members.Set(session.ID(), session)
+		joined.Done()
+		for {
+			if _, err := session.Receive(); err != nil {
+				return
+			}
+		}
+	}))
+	if err := group.Listen("tcp", "tcpServer", `{"addr":"127.0.0.1:0"}`, proto); err != nil {
+		t.Fatalf("Listen tcp err:%v\n", err)
+	}
+	if err := group.Listen("mem", "memServer", `{"name":"group"}`, proto); err != nil {
+		t.Fatalf("Listen mem err:%v\n", err)
+	}
+	if err := group.Listen("mem", "memServer", `{"name":"group2"}`, proto); err == nil {
+		t.Fatal("duplicate listener name accepted")
+	}
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- group.Run()
+	}()
+
+	var received sync.WaitGroup
+	received.Add(2)
+	client := server.HandlerFunc(func(session *server.Session) {
+		defer session.Close()
+		session.Send([]byte("join"))
+		if msg, err := session.Receive(); err != nil || string(msg.([]byte)) != "hello all" {
+			t.Errorf("recv %q err:%v\n", msg, err)
+		}
+		received.Done()
+	})
+	for _, c := range []struct{ adapter, config string }{
+		{"tcpClient", `{"addr":"` + serverAddr(group.Server("tcp")) + `"}`},
+		{"memClient", `{"name":"group"}`},
+	} {
+		cli, err := server.NewClient(c.adapter, c.config, proto, client)
+		if err != nil {
+			t.Fatalf("New client err:%v\n", err)
+		}
+		go cli.Run()
+	}
+
+	joined.Wait()
+	if group.Manager().Len() != 2 {
+		t.Fatalf("%d sessions in group, want 2\n", group.Manager().Len())
+	}
+	report := members.Broadcast([]byte("hello all"), nil)
+	if report.Sent != 2 {
+		t.Fatalf("unexpected report %+v\n", report)
+	}
+	received.Wait()
+
+	for _, stats := range group.Stats() {
+		if stats.Accepted != 1 || stats.Received != 1 || stats.Sent != 1 {
+			t.Fatalf("unexpected stats %+v\n", stats)
+		}
+	}
+
+	group.Stop()
+	select {
+	case err := <-runErr:
+		if err != nil {
+			t.Fatalf("Run err:%v\n", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run not returned after Stop")
+	}
+}
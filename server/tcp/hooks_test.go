@@ -0,0 +1,124 @@
+package tcpserver
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/gary163/seals/protocol"
+	"github.com/gary163/seals/server"
+)
+
+func TestSessionHooks(t *testing.T) {
+	proto, err := protocol.NewProtocol("binary", `{"fixlen":{}}`)
+	if err != nil {
+		t.Fatalf("New protocol err:%v\n", err)
+	}
+
+	events := make(chan string, 10)
+	sm := server.NewSessionManager()
+	sm.OnSessionCreated(func(session *server.Session) {
+		events <- "created"
+	})
+	sm.OnReceiveError(func(session *server.Session, err error) {
+		if err == io.EOF {
+			events <- "eof"
+		}
+	})
+	sm.OnSessionClosed(func(session *server.Session, reason error) {
+		if reason == nil {
+			events <- "closed"
+		}
+	})
+
+	srv, err := server.NewServerWithManager("tcpServer", `{"addr":"127.0.0.1:0"}`, proto, server.HandlerFunc(func(session *server.Session) {
+		defer session.Close()
+		for {
+			if _, err := session.Receive(); err != nil {
+				return
+			}
+		}
+	}), sm)
+	if err != nil {
+		t.Fatalf("New server err:%v\n", err)
+	}
+	go srv.Run()
+	defer srv.Stop()
+
+	cli, err := server.NewClient("tcpClient", `{"addr":"`+serverAddr(srv)+`"}`, proto, server.HandlerFunc(func(session *server.Session) {
+		session.Close()
+	}))
+	if err != nil {
+		t.Fatalf("New client err:%v\n", err)
+	}
+	cli.Run()
+
+	for _, want := range []string{"created", "eof", "closed"} {
+		select {
+		case got := <-events:
+			if got != want {
+				t.Fatalf("event %q, want %q\n", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event %q not fired\n", want)
+		}
+	}
+}
+
+var rejectedError = errors.New("rejected")
+
+func TestInterceptors(t *testing.T) {
+	proto, err := protocol.NewProtocol("binary", `{"fixlen":{}}`)
+	if err != nil {
+		t.Fatalf("New protocol err:%v\n", err)
+	}
+
+	//服务端：收到的消息统一转成大写后回显
+	sm := server.NewSessionManager()
+	sm.Use(server.Interceptor{
+		Receive: func(session *server.Session, next server.ReceiveFunc) (interface{}, error) {
+			msg, err := next()
+			if err != nil {
+				return nil, err
+			}
+			return bytes.ToUpper(msg.([]byte)), nil
+		},
+	})
+	srv, err := server.NewServerWithManager("tcpServer", `{"addr":"127.0.0.1:0"}`, proto, &tcpserver{t}, sm)
+	if err != nil {
+		t.Fatalf("New server err:%v\n", err)
+	}
+	go srv.Run()
+	defer srv.Stop()
+
+	//客户端：拒绝发送空消息
+	reject := server.Interceptor{
+		Send: func(session *server.Session, msg interface{}, next server.SendFunc) error {
+			if len(msg.([]byte)) == 0 {
+				return rejectedError
+			}
+			return next(msg)
+		},
+	}
+	cli, err := server.NewClient("tcpClient", `{"addr":"`+serverAddr(srv)+`"}`, proto, server.Intercept(server.HandlerFunc(func(session *server.Session) {
+		defer session.Close()
+		if err := session.Send([]byte{}); err != rejectedError {
+			t.Errorf("Send empty msg err:%v, want rejected\n", err)
+		}
+		session.Send([]byte("hello"))
+		recv, err := session.Receive()
+		if err != nil {
+			t.Errorf("Receive err:%v\n", err)
+			return
+		}
+		if string(recv.([]byte)) != "HELLO" {
+			t.Errorf("recv %q, want HELLO\n", recv)
+		}
+	}), reject))
+	if err != nil {
+		t.Fatalf("New client err:%v\n", err)
+	}
+	cli.Run()
+}
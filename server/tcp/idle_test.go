@@ -0,0 +1,117 @@
+package tcpserver
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gary163/seals/protocol"
+	"github.com/gary163/seals/server"
+)
+
+//clientOpts是追加在客户端配置addr之后的其他配置项
+func idleTest(t *testing.T, clientOpts string, want error) {
+	proto, err := protocol.NewProtocol("json", `{"fixlen":{}}`)
+	if err != nil {
+		t.Fatalf("New protocol err:%v\n", err)
+	}
+
+	reasons := make(chan error, 1)
+	srv, err := server.NewServer("tcpServer", `{"addr":"127.0.0.1:0","readIdleTimeout":"100ms"}`, proto, server.HandlerFunc(func(session *server.Session) {
+		defer session.Close()
+		for {
+			if _, err := session.Receive(); err != nil {
+				reason := session.CloseReason()
+				if reason != nil && session.Context().Err() != context.Canceled {
+					t.Errorf("session context not canceled after close\n")
+				}
+				reasons <- reason
+				return
+			}
+		}
+	}))
+	if err != nil {
+		t.Fatalf("New server err:%v\n", err)
+	}
+	go srv.Run()
+	defer srv.Stop()
+
+	cli, err := server.NewClient("tcpClient", `{"addr":"`+serverAddr(srv)+`"`+clientOpts+`}`, proto, server.HandlerFunc(func(session *server.Session) {
+		defer session.Close()
+		go func() {
+			for {
+				if _, err := session.Receive(); err != nil {
+					return
+				}
+			}
+		}()
+		time.Sleep(300 * time.Millisecond)
+	}))
+	if err != nil {
+		t.Fatalf("New client err:%v\n", err)
+	}
+	cli.Run()
+
+	select {
+	case reason := <-reasons:
+		if reason != want {
+			t.Fatalf("close reason %v, want %v\n", reason, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("server session not closed")
+	}
+}
+
+func TestReadIdleTimeout(t *testing.T) {
+	idleTest(t, "", server.ReadIdleTimeoutError)
+}
+
+func TestHeartbeat(t *testing.T) {
+	idleTest(t, `,"heartbeat":"30ms"`, nil)
+}
+
+//对端不再读取时，写空闲超时仍然能关闭Session，不会阻塞在刷出剩余消息上
+func TestWriteIdleStalledPeer(t *testing.T) {
+	proto, err := protocol.NewProtocol("binary", `{"fixlen":{"n":"4"}}`)
+	if err != nil {
+		t.Fatalf("New protocol err:%v\n", err)
+	}
+
+	//OnSessionClosed在codec关闭之后才触发
+	sm := server.NewSessionManager()
+	reasons := make(chan error, 1)
+	sm.OnSessionClosed(func(session *server.Session, reason error) {
+		reasons <- reason
+	})
+	srv, err := server.NewServerWithManager("tcpServer", `{"addr":"127.0.0.1:0","writeIdleTimeout":"200ms"}`, proto, server.HandlerFunc(func(session *server.Session) {
+		msg := make([]byte, 64*1024)
+		//写满socket缓冲区，之后的消息留在sendChan中
+		for i := 0; i < 1000; i++ {
+			if session.Send(msg) != nil {
+				return
+			}
+		}
+		<-session.Context().Done()
+	}), sm)
+	if err != nil {
+		t.Fatalf("New server err:%v\n", err)
+	}
+	go srv.Run()
+	defer srv.Stop()
+
+	conn, err := net.Dial("tcp", serverAddr(srv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	select {
+	case reason := <-reasons:
+		if reason != server.WriteIdleTimeoutError {
+			t.Fatalf("close reason %v, want WriteIdleTimeoutError\n", reason)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("session still open after writeIdleTimeout with a peer that never reads")
+	}
+}
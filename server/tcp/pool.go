@@ -0,0 +1,279 @@
+package tcpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gary163/seals/protocol"
+	"github.com/gary163/seals/server"
+)
+
+const (
+	defaultPoolSize     = 1
+	defaultPoolReplicas = 100
+)
+
+var (
+	PoolNoSessionError   = errors.New("Pool has no healthy session")
+	PoolKeyRequiredError = errors.New("Pool balance hash requires a key")
+)
+
+//连接多个后端的客户端，每个后端保持size个连接，断开的连接按backoff自动重连
+//除了tcpClient和ReconnectClient的配置外还支持：
+//addrs 逗号分隔的后端地址，必填，代替addr
+//size 每个后端的连接数，默认1
+//balance Send选择连接的策略：roundRobin(默认),leastPending,hash
+//replicas hash策略下每个后端在一致性哈希环上的虚拟节点数，默认100
+type PoolClient struct {
+	backends []*poolBackend
+	balance  string
+	ring     []ringNode //按hash排序
+	next     uint32
+	sm       *server.SessionManager
+	wg       sync.WaitGroup
+	closed   int32
+}
+
+type poolBackend struct {
+	addr  string
+	slots []*ReconnectClient
+}
+
+type ringNode struct {
+	hash    uint32
+	backend *poolBackend
+}
+
+func init() {
+	server.RegisterClient("tcpPoolClient", &PoolClient{})
+}
+
+//等同于server.NewClient("tcpPoolClient", ...)，返回可以直接Send的客户端
+func NewPoolClient(config string, protocol protocol.Protocol, handler server.Handler) (*PoolClient, error) {
+	client, err := server.NewClient("tcpPoolClient", config, protocol, handler)
+	if err != nil {
+		return nil, err
+	}
+	return client.(*PoolClient), nil
+}
+
+func (p *PoolClient) Init(config string, protocol protocol.Protocol, handler server.Handler, sm *server.SessionManager) error {
+	var cfg map[string]string
+	json.Unmarshal([]byte(config), &cfg)
+
+	var addrs []string
+	for _, addr := range strings.Split(cfg["addrs"], ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	if len(addrs) == 0 {
+		return errors.New("PoolClient:Missing addrs parameter")
+	}
+
+	size, replicas := defaultPoolSize, defaultPoolReplicas
+	ints := []struct {
+		key string
+		val *int
+	}{
+		{"size", &size},
+		{"replicas", &replicas},
+	}
+	for _, i := range ints {
+		if v, ok := cfg[i.key]; ok {
+			var err error
+			if *i.val, err = strconv.Atoi(v); err != nil || *i.val <= 0 {
+				return fmt.Errorf("PoolClient:%s %q is invalid", i.key, v)
+			}
+		}
+	}
+
+	p.balance = cfg["balance"]
+	switch p.balance {
+	case "":
+		p.balance = "roundRobin"
+	case "roundRobin", "leastPending", "hash":
+	default:
+		return fmt.Errorf("PoolClient:unknown balance %q", p.balance)
+	}
+
+	//连接上的消息都交给handler，断线期间Send由连接池换一个连接发送，不需要排队
+	if _, ok := cfg["queueSize"]; !ok {
+		cfg["queueSize"] = "0"
+	}
+	delete(cfg, "addrs")
+	p.backends = nil
+	p.ring = nil
+	for _, addr := range addrs {
+		cfg["addr"] = addr
+		slotConfig, _ := json.Marshal(cfg)
+		backend := &poolBackend{addr: addr}
+		for i := 0; i < size; i++ {
+			slot := &ReconnectClient{}
+			if err := slot.Init(string(slotConfig), protocol, handler, sm); err != nil {
+				return err
+			}
+			backend.slots = append(backend.slots, slot)
+		}
+		p.backends = append(p.backends, backend)
+		for i := 0; i < replicas; i++ {
+			p.ring = append(p.ring, ringNode{crc32.ChecksumIEEE([]byte(addr + "#" + strconv.Itoa(i))), backend})
+		}
+	}
+	sort.Slice(p.ring, func(i, j int) bool { return p.ring[i].hash < p.ring[j].hash })
+	p.sm = sm
+	return nil
+}
+
+//一直运行直到Close
+func (p *PoolClient) Run() {
+	for _, backend := range p.backends {
+		for _, slot := range backend.slots {
+			p.wg.Add(1)
+			go func(slot *ReconnectClient) {
+				defer p.wg.Done()
+				slot.Run()
+			}(slot)
+		}
+	}
+	p.wg.Wait()
+}
+
+//按balance选一个连接发送，hash策略需要使用SendKey
+func (p *PoolClient) Send(msg interface{}) error {
+	if p.balance == "hash" {
+		return PoolKeyRequiredError
+	}
+	return p.SendKey("", msg)
+}
+
+//hash策略下相同的key总是发往同一个连接，直到该连接断开；其他策略忽略key
+//选中的连接恰好断开时换一个连接重试，最多尝试连接总数次
+func (p *PoolClient) SendKey(key string, msg interface{}) error {
+	var err error
+	for _, backend := range p.backends {
+		for range backend.slots {
+			var session *server.Session
+			if session, err = p.Pick(key); err != nil {
+				return err
+			}
+			if err = session.Send(msg); err != server.SessionClosedError {
+				return err
+			}
+		}
+	}
+	return err
+}
+
+//按balance选一个可用的连接，没有时返回PoolNoSessionError
+func (p *PoolClient) Pick(key string) (*server.Session, error) {
+	if atomic.LoadInt32(&p.closed) == 1 {
+		return nil, ClientClosedError
+	}
+	var session *server.Session
+	switch p.balance {
+	case "hash":
+		if key == "" {
+			return nil, PoolKeyRequiredError
+		}
+		session = p.pickHash(key)
+	case "leastPending":
+		session = p.pickLeastPending()
+	default:
+		session = p.pickRoundRobin()
+	}
+	if session == nil {
+		return nil, PoolNoSessionError
+	}
+	return session, nil
+}
+
+func (p *PoolClient) sessions() []*server.Session {
+	var sessions []*server.Session
+	for _, backend := range p.backends {
+		sessions = append(sessions, backend.sessions()...)
+	}
+	return sessions
+}
+
+func (p *PoolClient) pickRoundRobin() *server.Session {
+	sessions := p.sessions()
+	if len(sessions) == 0 {
+		return nil
+	}
+	return sessions[int(atomic.AddUint32(&p.next, 1)-1)%len(sessions)]
+}
+
+//发送队列最短的连接，相同时轮流选择，避免总是压在第一个连接上
+func (p *PoolClient) pickLeastPending() *server.Session {
+	sessions := p.sessions()
+	if len(sessions) == 0 {
+		return nil
+	}
+	start := int(atomic.AddUint32(&p.next, 1)-1) % len(sessions)
+	best := sessions[start]
+	for i := 1; i < len(sessions); i++ {
+		session := sessions[(start+i)%len(sessions)]
+		if session.Pending() < best.Pending() {
+			best = session
+		}
+	}
+	return best
+}
+
+//在哈希环上顺时针找第一个有可用连接的后端，再在该后端内按key选固定的连接
+//选中的连接断开时顺序找下一个，其他连接的断开和重连不会让key换连接
+func (p *PoolClient) pickHash(key string) *server.Session {
+	hash := crc32.ChecksumIEEE([]byte(key))
+	start := sort.Search(len(p.ring), func(i int) bool { return p.ring[i].hash >= hash })
+	tried := make(map[*poolBackend]bool)
+	for i := 0; i < len(p.ring) && len(tried) < len(p.backends); i++ {
+		backend := p.ring[(start+i)%len(p.ring)].backend
+		if tried[backend] {
+			continue
+		}
+		tried[backend] = true
+		slot := int(hash % uint32(len(backend.slots)))
+		for j := 0; j < len(backend.slots); j++ {
+			if session := backend.slots[(slot+j)%len(backend.slots)].Session(); session != nil {
+				return session
+			}
+		}
+	}
+	return nil
+}
+
+//当前可用的连接数
+func (p *PoolClient) Sessions() int {
+	return len(p.sessions())
+}
+
+func (p *PoolClient) Close() error {
+	if !atomic.CompareAndSwapInt32(&p.closed, 0, 1) {
+		return ClientClosedError
+	}
+	for _, backend := range p.backends {
+		for _, slot := range backend.slots {
+			slot.Close()
+		}
+	}
+	p.sm.Destroy()
+	return nil
+}
+
+func (b *poolBackend) sessions() []*server.Session {
+	var sessions []*server.Session
+	for _, slot := range b.slots {
+		if session := slot.Session(); session != nil {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions
+}
@@ -0,0 +1,276 @@
+package tcpserver
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gary163/seals/protocol"
+	"github.com/gary163/seals/server"
+)
+
+func TestPoolClient(t *testing.T) {
+	proto, err := protocol.NewProtocol("binary", `{"fixlen":{}}`)
+	if err != nil {
+		t.Fatalf("New protocol err:%v\n", err)
+	}
+
+	type delivery struct {
+		backend string
+		msg     string
+	}
+	received := make(chan delivery, 100)
+	//返回实际监听的地址，addr为127.0.0.1:0时由系统分配端口
+	startServer := func(addr string) (server.Server, string) {
+		var bound string
+		srv, err := server.NewServer("tcpServer", `{"addr":"`+addr+`"}`, proto, server.HandlerFunc(func(session *server.Session) {
+			defer session.Close()
+			for {
+				msg, err := session.Receive()
+				if err != nil {
+					return
+				}
+				received <- delivery{bound, string(msg.([]byte))}
+			}
+		}))
+		if err != nil {
+			t.Fatalf("New server err:%v\n", err)
+		}
+		bound = serverAddr(srv)
+		go srv.Run()
+		return srv, bound
+	}
+	recv := func() delivery {
+		select {
+		case d := <-received:
+			return d
+		case <-time.After(2 * time.Second):
+			t.Fatalf("server did not receive\n")
+		}
+		return delivery{}
+	}
+	waitSessions := func(cli *PoolClient, n int) {
+		deadline := time.Now().Add(2 * time.Second)
+		for cli.Sessions() != n {
+			if time.Now().After(deadline) {
+				t.Fatalf("pool has %d sessions, want %d\n", cli.Sessions(), n)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	drain := func(session *server.Session) {
+		for {
+			if _, err := session.Receive(); err != nil {
+				return
+			}
+		}
+	}
+
+	srvA, addrA := startServer("127.0.0.1:0")
+	srvB, addrB := startServer("127.0.0.1:0")
+	defer srvB.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	if _, err := NewPoolClient(`{"addrs":"`+addrA+`","balance":"random"}`, proto, server.HandlerFunc(drain)); err == nil {
+		t.Fatalf("unknown balance should fail\n")
+	}
+
+	cli, err := NewPoolClient(`{"addrs":"`+addrA+`,`+addrB+`","size":"2","backoffMin":"20ms","backoffMax":"50ms"}`, proto, server.HandlerFunc(drain))
+	if err != nil {
+		t.Fatalf("New client err:%v\n", err)
+	}
+	go cli.Run()
+	defer cli.Close()
+	waitSessions(cli, 4)
+
+	//轮询时两个后端都会收到
+	counts := make(map[string]int)
+	for i := 0; i < 8; i++ {
+		if err := cli.Send([]byte("rr")); err != nil {
+			t.Fatalf("Send err:%v\n", err)
+		}
+		counts[recv().backend]++
+	}
+	if counts[addrA] != 4 || counts[addrB] != 4 {
+		t.Fatalf("round robin counts %v\n", counts)
+	}
+
+	//一个后端断开后，它的连接被移出连接池，消息都发往另一个后端
+	srvA.Stop()
+	waitSessions(cli, 2)
+	for i := 0; i < 4; i++ {
+		if err := cli.Send([]byte("failover")); err != nil {
+			t.Fatalf("Send err:%v\n", err)
+		}
+		if d := recv(); d.backend != addrB {
+			t.Fatalf("message went to %s after %s stopped\n", d.backend, addrA)
+		}
+	}
+
+	//后端恢复后连接自动补齐
+	srvA, _ = startServer(addrA)
+	defer srvA.Stop()
+	waitSessions(cli, 4)
+}
+
+func TestPoolClientHash(t *testing.T) {
+	proto, err := protocol.NewProtocol("binary", `{"fixlen":{}}`)
+	if err != nil {
+		t.Fatalf("New protocol err:%v\n", err)
+	}
+
+	received := make(chan int64, 100)
+	srv, err := server.NewServer("tcpServer", `{"addr":"127.0.0.1:0"}`, proto, server.HandlerFunc(func(session *server.Session) {
+		defer session.Close()
+		for {
+			if _, err := session.Receive(); err != nil {
+				return
+			}
+			received <- session.ID()
+		}
+	}))
+	if err != nil {
+		t.Fatalf("New server err:%v\n", err)
+	}
+	go srv.Run()
+	defer srv.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	cli, err := NewPoolClient(`{"addrs":"`+serverAddr(srv)+`","size":"4","balance":"hash"}`, proto, server.HandlerFunc(func(session *server.Session) {
+		for {
+			if _, err := session.Receive(); err != nil {
+				return
+			}
+		}
+	}))
+	if err != nil {
+		t.Fatalf("New client err:%v\n", err)
+	}
+	go cli.Run()
+	defer cli.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for cli.Sessions() != 4 {
+		if time.Now().After(deadline) {
+			t.Fatalf("pool has %d sessions, want 4\n", cli.Sessions())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := cli.Send([]byte("nokey")); err != PoolKeyRequiredError {
+		t.Fatalf("Send without key err:%v\n", err)
+	}
+
+	//相同的key总是落在同一个连接上
+	for _, key := range []string{"user-1", "user-2", "user-3"} {
+		var first int64
+		for i := 0; i < 5; i++ {
+			if err := cli.SendKey(key, []byte(key)); err != nil {
+				t.Fatalf("SendKey err:%v\n", err)
+			}
+			var id int64
+			select {
+			case id = <-received:
+			case <-time.After(2 * time.Second):
+				t.Fatalf("server did not receive %s\n", key)
+			}
+			if i == 0 {
+				first = id
+			} else if id != first {
+				t.Fatalf("key %s moved from session %d to %d\n", key, first, id)
+			}
+		}
+	}
+
+	//同一后端上其他连接断开和重连时，key仍然落在原来的连接上
+	sticky, err := cli.Pick("user-1")
+	if err != nil {
+		t.Fatalf("Pick err:%v\n", err)
+	}
+	for i := 0; ; i++ {
+		other, _ := cli.Pick("other-" + strconv.Itoa(i))
+		if other != sticky {
+			other.Close()
+			break
+		}
+	}
+	if session, _ := cli.Pick("user-1"); session != sticky {
+		t.Fatalf("key moved after another connection closed\n")
+	}
+	for cli.Sessions() != 4 {
+		if time.Now().After(deadline.Add(2 * time.Second)) {
+			t.Fatalf("pool has %d sessions, want 4\n", cli.Sessions())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if session, _ := cli.Pick("user-1"); session != sticky {
+		t.Fatalf("key moved after another connection reconnected\n")
+	}
+}
+
+//一个连接的对端不读数据时，消息都发往发送队列更短的连接
+func TestPoolClientLeastPending(t *testing.T) {
+	proto, err := protocol.NewProtocol("binary", `{"fixlen":{}}`)
+	if err != nil {
+		t.Fatalf("New protocol err:%v\n", err)
+	}
+
+	var accepted, received int32
+	stop := make(chan struct{})
+	srv, err := server.NewServer("tcpServer", `{"addr":"127.0.0.1:0"}`, proto, server.HandlerFunc(func(session *server.Session) {
+		defer session.Close()
+		if atomic.AddInt32(&accepted, 1) == 1 {
+			<-stop
+			return
+		}
+		for {
+			if _, err := session.Receive(); err != nil {
+				return
+			}
+			atomic.AddInt32(&received, 1)
+		}
+	}))
+	if err != nil {
+		t.Fatalf("New server err:%v\n", err)
+	}
+	go srv.Run()
+	defer srv.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	cli, err := NewPoolClient(`{"addrs":"`+serverAddr(srv)+`","size":"2","balance":"leastPending","sendChanSize":"100","writeBuffer":"4096"}`, proto, server.HandlerFunc(func(session *server.Session) {
+		for {
+			if _, err := session.Receive(); err != nil {
+				return
+			}
+		}
+	}))
+	if err != nil {
+		t.Fatalf("New client err:%v\n", err)
+	}
+	go cli.Run()
+	defer cli.Close()
+	defer close(stop) //先让服务端关闭不读数据的连接，cli.Close才不会阻塞在发送剩余的消息上
+	deadline := time.Now().Add(2 * time.Second)
+	for cli.Sessions() != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("pool has %d sessions, want 2\n", cli.Sessions())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	const n = 60
+	msg := make([]byte, 30000)
+	for i := 0; i < n; i++ {
+		if err := cli.Send(msg); err != nil {
+			t.Fatalf("Send err:%v\n", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	deadline = time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&received) < n*3/4 {
+		if time.Now().After(deadline) {
+			t.Fatalf("reading backend received %d of %d messages\n", atomic.LoadInt32(&received), n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,84 @@
+package tcpserver
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gary163/seals/protocol"
+	"github.com/gary163/seals/server"
+)
+
+func TestProxyProtocol(t *testing.T) {
+	proto, err := protocol.NewProtocol("binary", `{"fixlen":{}}`)
+	if err != nil {
+		t.Fatalf("New protocol err:%v\n", err)
+	}
+
+	addrs := make(chan [2]string, 1)
+	srv, err := server.NewServer("tcpServer", `{"addr":"127.0.0.1:0","proxyProtocol":"true","proxyTrusted":"127.0.0.1"}`, proto, server.HandlerFunc(func(session *server.Session) {
+		defer session.Close()
+		if msg, err := session.Receive(); err != nil || string(msg.([]byte)) != "hello" {
+			t.Errorf("recv %q err:%v\n", msg, err)
+		}
+		addrs <- [2]string{session.RemoteAddr().String(), session.LocalAddr().String()}
+	}))
+	if err != nil {
+		t.Fatalf("New server err:%v\n", err)
+	}
+	go srv.Run()
+	defer srv.Stop()
+
+	conn, err := net.Dial("tcp", serverAddr(srv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("PROXY TCP4 203.0.113.7 198.51.100.1 40000 443\r\n"))
+	codec, _ := proto.NewCodec(conn)
+	codec.Send([]byte("hello"))
+
+	select {
+	case got := <-addrs:
+		if got[0] != "203.0.113.7:40000" || got[1] != "198.51.100.1:443" {
+			t.Fatalf("session addrs %v, want real client and destination\n", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("message not received")
+	}
+}
+
+//不在proxyTrusted中的来源发来的PROXY头不会改变Session的地址
+func TestProxyProtocolUntrusted(t *testing.T) {
+	proto, err := protocol.NewProtocol("binary", `{"fixlen":{}}`)
+	if err != nil {
+		t.Fatalf("New protocol err:%v\n", err)
+	}
+
+	addrs := make(chan string, 1)
+	srv, err := server.NewServer("tcpServer", `{"addr":"127.0.0.1:0","proxyProtocol":"true","proxyTrusted":"10.0.0.1"}`, proto, server.HandlerFunc(func(session *server.Session) {
+		defer session.Close()
+		addrs <- session.RemoteAddr().String()
+	}))
+	if err != nil {
+		t.Fatalf("New server err:%v\n", err)
+	}
+	go srv.Run()
+	defer srv.Stop()
+
+	conn, err := net.Dial("tcp", serverAddr(srv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("PROXY TCP4 203.0.113.7 198.51.100.1 40000 443\r\n"))
+
+	select {
+	case got := <-addrs:
+		if got != conn.LocalAddr().String() {
+			t.Fatalf("session remote addr %s, want the real peer %s\n", got, conn.LocalAddr())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("session not created")
+	}
+}
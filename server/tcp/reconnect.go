@@ -0,0 +1,330 @@
+package tcpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gary163/seals/protocol"
+	"github.com/gary163/seals/server"
+)
+
+const (
+	defaultBackoffMin    = 100 * time.Millisecond
+	defaultBackoffMax    = 30 * time.Second
+	defaultBackoffFactor = 2.0
+	defaultBackoffJitter = 0.2
+	defaultQueueSize     = 1024
+)
+
+var (
+	ReconnectQueueFullError = errors.New("Reconnect client queue is full")
+	ClientClosedError       = errors.New("Client Closed")
+)
+
+//断线自动重连的长连接客户端，除了tcpClient的配置外还支持：
+//backoffMin,backoffMax 重连间隔的下限和上限，默认100ms和30s
+//backoffFactor 每次失败后间隔的倍数，默认2
+//backoffJitter 间隔随机浮动的比例，默认0.2，避免大量客户端同时重连
+//queueSize 断线期间Send的消息最多缓存的条数，默认1024，重连后按顺序发出
+//maxRetries 连续重连失败的最大次数，0表示一直重连
+type ReconnectClient struct {
+	tcpClient
+	backoffMin    time.Duration
+	backoffMax    time.Duration
+	backoffFactor float64
+	backoffJitter float64
+	queueSize     int
+	maxRetries    int
+
+	mu             sync.Mutex
+	session        *server.Session //当前的连接，断线期间为nil
+	queue          []interface{}
+	closed         bool
+	closeChan      chan struct{}
+	onConnected    []func(session *server.Session)
+	onDisconnected []func(session *server.Session, reason error)
+}
+
+func init() {
+	server.RegisterClient("tcpReconnectClient", &ReconnectClient{})
+}
+
+//等同于server.NewClient("tcpReconnectClient", ...)，返回可以直接Send的客户端
+func NewReconnectClient(config string, protocol protocol.Protocol, handler server.Handler) (*ReconnectClient, error) {
+	client, err := server.NewClient("tcpReconnectClient", config, protocol, handler)
+	if err != nil {
+		return nil, err
+	}
+	return client.(*ReconnectClient), nil
+}
+
+func (c *ReconnectClient) Init(config string, protocol protocol.Protocol, handler server.Handler, sm *server.SessionManager) error {
+	if err := c.tcpClient.Init(config, protocol, handler, sm); err != nil {
+		return err
+	}
+	var cfg map[string]string
+	json.Unmarshal([]byte(config), &cfg)
+
+	durations := []struct {
+		key string
+		val *time.Duration
+		def time.Duration
+	}{
+		{"backoffMin", &c.backoffMin, defaultBackoffMin},
+		{"backoffMax", &c.backoffMax, defaultBackoffMax},
+	}
+	for _, d := range durations {
+		*d.val = d.def
+		if v, ok := cfg[d.key]; ok {
+			var err error
+			if *d.val, err = time.ParseDuration(v); err != nil || *d.val <= 0 {
+				return fmt.Errorf("ReconnectClient:%s %q is invalid", d.key, v)
+			}
+		}
+	}
+
+	floats := []struct {
+		key string
+		val *float64
+		def float64
+	}{
+		{"backoffFactor", &c.backoffFactor, defaultBackoffFactor},
+		{"backoffJitter", &c.backoffJitter, defaultBackoffJitter},
+	}
+	for _, f := range floats {
+		*f.val = f.def
+		if v, ok := cfg[f.key]; ok {
+			var err error
+			if *f.val, err = strconv.ParseFloat(v, 64); err != nil || *f.val < 0 {
+				return fmt.Errorf("ReconnectClient:%s %q is invalid", f.key, v)
+			}
+		}
+	}
+	if c.backoffFactor < 1 || c.backoffJitter > 1 {
+		return errors.New("ReconnectClient:backoffFactor must be >= 1 and backoffJitter <= 1")
+	}
+
+	ints := []struct {
+		key string
+		val *int
+		def int
+	}{
+		{"queueSize", &c.queueSize, defaultQueueSize},
+		{"maxRetries", &c.maxRetries, 0},
+	}
+	for _, i := range ints {
+		*i.val = i.def
+		if v, ok := cfg[i.key]; ok {
+			var err error
+			if *i.val, err = strconv.Atoi(v); err != nil || *i.val < 0 {
+				return fmt.Errorf("ReconnectClient:%s %q is invalid", i.key, v)
+			}
+		}
+	}
+
+	c.closeChan = make(chan struct{})
+	return nil
+}
+
+//每次连接建立后、缓存的消息发出之前调用，可以在这里用session.Send发送登录等消息
+//回调中调用ReconnectClient的Send会进入队列，排在已缓存的消息之后
+func (c *ReconnectClient) OnConnected(callback func(session *server.Session)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onConnected = append(c.onConnected, callback)
+}
+
+//连接断开后调用，reason为Session的关闭原因
+func (c *ReconnectClient) OnDisconnected(callback func(session *server.Session, reason error)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onDisconnected = append(c.onDisconnected, callback)
+}
+
+//已连接时直接发送，断线期间放入队列，队列满时返回ReconnectQueueFullError
+//发送不持有锁，阻塞的Send不会影响Session、OnConnected和Close
+func (c *ReconnectClient) Send(msg interface{}) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ClientClosedError
+	}
+	session := c.session
+	c.mu.Unlock()
+
+	if session != nil {
+		err := session.Send(msg)
+		if err != server.SessionClosedError {
+			return err
+		}
+		//连接刚刚断开，还没有被Run发现
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return ClientClosedError
+	}
+	if len(c.queue) >= c.queueSize {
+		return ReconnectQueueFullError
+	}
+	c.queue = append(c.queue, msg)
+	return nil
+}
+
+//当前的Session，断线期间返回nil
+func (c *ReconnectClient) Session() *server.Session {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.session
+}
+
+//一直运行直到Close，或连续失败超过maxRetries次
+func (c *ReconnectClient) Run() {
+	failures := 0
+	for {
+		conn, err := c.dialOnce()
+		var session *server.Session
+		if err == nil {
+			session, err = c.newSession(conn)
+		}
+		if err != nil {
+			failures++
+			if c.maxRetries > 0 && failures > c.maxRetries {
+				log.Printf("Reconnect client gave up after %d retries:%v\n", c.maxRetries, err)
+				return
+			}
+			if !c.wait(c.backoff(failures)) {
+				return
+			}
+			continue
+		}
+		failures = 0
+
+		flushed, ok := c.connected(session)
+		if !ok {
+			session.Close()
+			return
+		}
+		//缓存的消息没有发完时Session已经关闭，直接重连
+		if flushed {
+			c.handler.Handle(session)
+		}
+		session.Close()
+		c.disconnected(session)
+
+		select {
+		case <-c.closeChan:
+			return
+		default:
+		}
+	}
+}
+
+func (c *ReconnectClient) dialOnce() (net.Conn, error) {
+	if c.timeout > 0 {
+		return net.DialTimeout("tcp", c.addr, time.Duration(c.timeout))
+	}
+	return net.Dial("tcp", c.addr)
+}
+
+//第n次失败后的等待时间：backoffMin*backoffFactor^(n-1)，不超过backoffMax，再加上随机浮动
+func (c *ReconnectClient) backoff(n int) time.Duration {
+	d := float64(c.backoffMin) * math.Pow(c.backoffFactor, float64(n-1))
+	if d > float64(c.backoffMax) {
+		d = float64(c.backoffMax)
+	}
+	d *= 1 + c.backoffJitter*(2*rand.Float64()-1)
+	return time.Duration(d)
+}
+
+//Close时返回false
+func (c *ReconnectClient) wait(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-c.closeChan:
+		return false
+	}
+}
+
+//先调用回调，再按顺序发出断线期间缓存的消息，之后的Send直接发送
+//回调和发送都不持有锁，发送期间的Send继续进入队列，队列清空后才设置c.session以保证顺序
+//发送失败时未发出的消息放回队首(不超过queueSize)并关闭Session，flushed为false，由Run重连后再发
+//Close后ok为false
+func (c *ReconnectClient) connected(session *server.Session) (flushed bool, ok bool) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return false, false
+	}
+	callbacks := c.onConnected
+	c.mu.Unlock()
+	for _, callback := range callbacks {
+		callback(session)
+	}
+
+	for {
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			return false, false
+		}
+		if len(c.queue) == 0 {
+			c.session = session
+			c.mu.Unlock()
+			return true, true
+		}
+		queue := c.queue
+		c.queue = nil
+		c.mu.Unlock()
+
+		for i, msg := range queue {
+			if err := session.Send(msg); err != nil {
+				c.mu.Lock()
+				c.queue = append(queue[i:], c.queue...)
+				if len(c.queue) > c.queueSize {
+					c.queue = c.queue[:c.queueSize]
+				}
+				c.mu.Unlock()
+				session.Close()
+				return false, true
+			}
+		}
+	}
+}
+
+func (c *ReconnectClient) disconnected(session *server.Session) {
+	c.mu.Lock()
+	if c.session == session {
+		c.session = nil
+	}
+	callbacks := c.onDisconnected
+	c.mu.Unlock()
+	for _, callback := range callbacks {
+		callback(session, session.CloseReason())
+	}
+}
+
+func (c *ReconnectClient) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ClientClosedError
+	}
+	c.closed = true
+	close(c.closeChan)
+	c.mu.Unlock()
+	c.sm.Destroy()
+	return nil
+}
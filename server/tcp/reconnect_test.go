@@ -0,0 +1,197 @@
+package tcpserver
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gary163/seals/protocol"
+	"github.com/gary163/seals/server"
+)
+
+func TestReconnectClient(t *testing.T) {
+	proto, err := protocol.NewProtocol("binary", `{"fixlen":{}}`)
+	if err != nil {
+		t.Fatalf("New protocol err:%v\n", err)
+	}
+
+	received := make(chan string, 10)
+	startServer := func(addr string) server.Server {
+		srv, err := server.NewServer("tcpServer", `{"addr":"`+addr+`"}`, proto, server.HandlerFunc(func(session *server.Session) {
+			defer session.Close()
+			for {
+				msg, err := session.Receive()
+				if err != nil {
+					return
+				}
+				received <- string(msg.([]byte))
+			}
+		}))
+		if err != nil {
+			t.Fatalf("New server err:%v\n", err)
+		}
+		go srv.Run()
+		return srv
+	}
+	expect := func(want string) {
+		select {
+		case got := <-received:
+			if got != want {
+				t.Fatalf("server recv %q, want %q\n", got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("server did not receive %q\n", want)
+		}
+	}
+
+	//先启动一次服务端取得系统分配的端口，之后在这个端口上停止和重启
+	srv := startServer("127.0.0.1:0")
+	addr := serverAddr(srv)
+	srv.Stop()
+
+	cli, err := NewReconnectClient(`{"addr":"`+addr+`","backoffMin":"20ms","backoffMax":"100ms","queueSize":"2"}`, proto, server.HandlerFunc(func(session *server.Session) {
+		for {
+			if _, err := session.Receive(); err != nil {
+				return
+			}
+		}
+	}))
+	if err != nil {
+		t.Fatalf("New client err:%v\n", err)
+	}
+	connected := make(chan struct{}, 10)
+	disconnected := make(chan struct{}, 10)
+	first := true
+	cli.OnConnected(func(session *server.Session) {
+		session.Send([]byte("login"))
+		//回调中的Send排在缓存的消息之后
+		if first {
+			first = false
+			if err := cli.Send([]byte("callback")); err != nil {
+				t.Errorf("Send in OnConnected err:%v\n", err)
+			}
+		}
+		connected <- struct{}{}
+	})
+	cli.OnDisconnected(func(session *server.Session, reason error) {
+		disconnected <- struct{}{}
+	})
+
+	//服务端还没有启动，消息先进入队列
+	if err := cli.Send([]byte("queued1")); err != nil {
+		t.Fatalf("Send while disconnected err:%v\n", err)
+	}
+	runDone := make(chan struct{})
+	go func() {
+		cli.Run()
+		close(runDone)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	srv = startServer(addr)
+	<-connected
+	expect("login")
+	expect("queued1")
+	expect("callback")
+	if err := cli.Send([]byte("direct")); err != nil {
+		t.Fatalf("Send err:%v\n", err)
+	}
+	expect("direct")
+
+	//服务端重启期间的消息在重连后按顺序发出，超过queueSize的被拒绝
+	srv.Stop()
+	<-disconnected
+	cli.Send([]byte("queued2"))
+	cli.Send([]byte("queued3"))
+	if err := cli.Send([]byte("overflow")); err != ReconnectQueueFullError {
+		t.Fatalf("Send to full queue err:%v\n", err)
+	}
+	srv = startServer(addr)
+	defer srv.Stop()
+	<-connected
+	expect("login")
+	expect("queued2")
+	expect("queued3")
+
+	cli.Close()
+	select {
+	case <-runDone:
+	case <-time.After(time.Second):
+		t.Fatal("Run not returned after Close")
+	}
+	if err := cli.Send([]byte("closed")); err != ClientClosedError {
+		t.Fatalf("Send after Close err:%v\n", err)
+	}
+}
+
+//缓存的消息没有发完时不调用handler，直接重连，未发出的消息按顺序留在队列中
+func TestReconnectClientPartialFlush(t *testing.T) {
+	proto, err := protocol.NewProtocol("binary", `{"fixlen":{}}`)
+	if err != nil {
+		t.Fatalf("New protocol err:%v\n", err)
+	}
+
+	received := make(chan string, 10)
+	srv, err := server.NewServer("tcpServer", `{"addr":"127.0.0.1:0"}`, proto, server.HandlerFunc(func(session *server.Session) {
+		defer session.Close()
+		for {
+			msg, err := session.Receive()
+			if err != nil {
+				return
+			}
+			received <- string(msg.([]byte))
+		}
+	}))
+	if err != nil {
+		t.Fatalf("New server err:%v\n", err)
+	}
+	go srv.Run()
+	defer srv.Stop()
+	addr := serverAddr(srv)
+
+	var handled int32
+	cli, err := NewReconnectClient(`{"addr":"`+addr+`","backoffMin":"20ms","backoffMax":"100ms","queueSize":"2"}`, proto, server.HandlerFunc(func(session *server.Session) {
+		atomic.AddInt32(&handled, 1)
+		for {
+			if _, err := session.Receive(); err != nil {
+				return
+			}
+		}
+	}))
+	if err != nil {
+		t.Fatalf("New client err:%v\n", err)
+	}
+	defer cli.Close()
+	connects := 0
+	connected := make(chan struct{}, 10)
+	cli.OnConnected(func(session *server.Session) {
+		connects++
+		if connects == 1 {
+			session.Close() //第一次连接上发送缓存的消息会失败
+		}
+		connected <- struct{}{}
+	})
+	cli.Send([]byte("queued1"))
+	cli.Send([]byte("queued2"))
+	go cli.Run()
+
+	<-connected
+	<-connected
+	for _, want := range []string{"queued1", "queued2"} {
+		select {
+		case got := <-received:
+			if got != want {
+				t.Fatalf("server recv %q, want %q\n", got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("server did not receive %q\n", want)
+		}
+	}
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&handled) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&handled); n != 1 {
+		t.Fatalf("handler called %d times, want only for the flushed session\n", n)
+	}
+}
@@ -0,0 +1,123 @@
+package tcpserver
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gary163/seals/protocol"
+	"github.com/gary163/seals/server"
+)
+
+const restartChildEnv = "SEALS_RESTART_TEST_CHILD"
+
+//Restart启动的子进程只运行这里的逻辑：用继承的socket回显一次消息后退出
+func TestMain(m *testing.M) {
+	if os.Getenv(restartChildEnv) != "" {
+		os.Exit(restartChild())
+	}
+	os.Exit(m.Run())
+}
+
+func restartChild() int {
+	proto, err := protocol.NewProtocol("binary", `{"fixlen":{}}`)
+	if err != nil {
+		return 1
+	}
+	done := make(chan struct{})
+	//与父进程的配置相同才能取到继承的socket
+	srv, err := server.NewServer("tcpServer", `{"addr":"127.0.0.1:0"}`, proto, server.HandlerFunc(func(session *server.Session) {
+		defer close(done)
+		defer session.Close()
+		if msg, err := session.Receive(); err == nil {
+			session.Send(append([]byte("child:"), msg.([]byte)...))
+		}
+	}))
+	if err != nil {
+		return 1
+	}
+	go srv.Run()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+	}
+	srv.Stop()
+	return 0
+}
+
+func TestRestart(t *testing.T) {
+	proto, err := protocol.NewProtocol("binary", `{"fixlen":{}}`)
+	if err != nil {
+		t.Fatalf("New protocol err:%v\n", err)
+	}
+	srv, err := server.NewServer("tcpServer", `{"addr":"127.0.0.1:0"}`, proto, server.HandlerFunc(func(session *server.Session) {
+		defer session.Close()
+		session.Receive()
+	}))
+	if err != nil {
+		t.Fatalf("New server err:%v\n", err)
+	}
+	addr := serverAddr(srv)
+	go srv.Run()
+
+	os.Setenv(restartChildEnv, "1")
+	process, err := server.Restart()
+	os.Unsetenv(restartChildEnv)
+	if err != nil {
+		t.Fatalf("Restart err:%v\n", err)
+	}
+
+	//父进程关闭后端口仍由子进程监听
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	srv.(server.GracefulServer).StopGracefully(ctx)
+
+	reply := make(chan string, 1)
+	cli, err := server.NewClient("tcpClient", `{"addr":"`+addr+`"}`, proto, server.HandlerFunc(func(session *server.Session) {
+		defer session.Close()
+		session.Send([]byte("hello"))
+		if msg, err := session.Receive(); err == nil {
+			reply <- string(msg.([]byte))
+		}
+	}))
+	if err != nil {
+		t.Fatalf("New client err:%v\n", err)
+	}
+	cli.Run()
+	select {
+	case got := <-reply:
+		if got != "child:hello" {
+			t.Fatalf("reply %q, want child:hello\n", got)
+		}
+	default:
+		t.Fatal("no reply from child process")
+	}
+
+	state, err := process.Wait()
+	if err != nil || !state.Success() {
+		t.Fatalf("child exit %v err:%v\n", state, err)
+	}
+}
+
+func TestReusePort(t *testing.T) {
+	proto, err := protocol.NewProtocol("binary", `{"fixlen":{}}`)
+	if err != nil {
+		t.Fatalf("New protocol err:%v\n", err)
+	}
+	handler := server.HandlerFunc(func(session *server.Session) {
+		session.Close()
+	})
+	addr := "127.0.0.1:0"
+	for i := 0; i < 2; i++ {
+		srv, err := server.NewServer("tcpServer", `{"addr":"`+addr+`","reusePort":"true"}`, proto, handler)
+		if err != nil {
+			t.Fatalf("New server %d with reusePort err:%v\n", i, err)
+		}
+		defer srv.Stop()
+		addr = serverAddr(srv)
+	}
+	if _, err := server.NewServer("tcpServer", `{"addr":"`+addr+`"}`, proto, handler); err == nil {
+		t.Fatal("listen without reusePort on a used port succeeded")
+	}
+}
@@ -0,0 +1,85 @@
+package tcpserver
+
+import (
+	"testing"
+
+	"github.com/gary163/seals/protocol"
+	_ "github.com/gary163/seals/protocol/json"
+	"github.com/gary163/seals/server"
+)
+
+type routeEcho struct {
+	Text string
+}
+
+type routePing struct {
+	Seq int
+}
+
+type routeUnknown struct {
+	Value int
+}
+
+func TestRouter(t *testing.T) {
+	proto, err := protocol.NewProtocol("json", `{"fixlen":{}}`)
+	if err != nil {
+		t.Fatalf("New protocol err:%v\n", err)
+	}
+	proto.Register(&routeEcho{})
+	proto.Register(&routePing{})
+
+	router := server.NewRouter(proto)
+	router.Route(&routeEcho{}, func(session *server.Session, msg interface{}) {
+		session.Send(msg)
+	})
+	router.RouteName("tcp_routePing", func(session *server.Session, msg interface{}) {
+		if ping := msg.(*routePing); ping.Seq == 1 {
+			session.Send(&routeEcho{Text: "pong"})
+		}
+	})
+	router.Fallback(func(session *server.Session, msg interface{}) {
+		session.Send(&routeEcho{Text: "fallback"})
+	})
+
+	srv, err := server.NewServer("tcpServer", `{"addr":"127.0.0.1:0"}`, proto, router)
+	if err != nil {
+		t.Fatalf("New server err:%v\n", err)
+	}
+	go srv.Run()
+	defer srv.Stop()
+
+	cli, err := server.NewClient("tcpClient", `{"addr":"`+serverAddr(srv)+`"}`, proto, server.HandlerFunc(func(session *server.Session) {
+		defer session.Close()
+		cases := []struct {
+			send interface{}
+			want string
+		}{
+			{&routeEcho{Text: "hello"}, "hello"},
+			{&routePing{Seq: 1}, "pong"},
+			{&routeUnknown{Value: 1}, "fallback"},
+		}
+		for _, c := range cases {
+			if err := session.Send(c.send); err != nil {
+				t.Errorf("Send err:%v\n", err)
+				return
+			}
+			recv, err := session.Receive()
+			if err != nil {
+				t.Errorf("Receive err:%v\n", err)
+				return
+			}
+			echo, ok := recv.(*routeEcho)
+			if !ok {
+				t.Errorf("recv type %T not *routeEcho\n", recv)
+				return
+			}
+			if echo.Text != c.want {
+				t.Errorf("recv %q, want %q\n", echo.Text, c.want)
+			}
+		}
+	}))
+	if err != nil {
+		t.Fatalf("New client err:%v\n", err)
+	}
+	cli.Run()
+}
@@ -0,0 +1,109 @@
+package tcpserver
+
+import (
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gary163/seals/protocol"
+	"github.com/gary163/seals/server"
+)
+
+func TestSocketOptions(t *testing.T) {
+	opts, err := server.ParseSocketOptions(map[string]string{"noDelay": "false", "keepAlive": "0", "readBuffer": "65536", "linger": "0"})
+	if err != nil {
+		t.Fatalf("Parse socket options err:%v\n", err)
+	}
+	for _, cfg := range []map[string]string{{"noDelay": "x"}, {"keepAlive": "1h2"}, {"readBuffer": "-1"}, {"linger": "-1"}} {
+		if _, err := server.ParseSocketOptions(cfg); err == nil {
+			t.Fatalf("Parse %v should fail\n", cfg)
+		}
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen err:%v\n", err)
+	}
+	defer l.Close()
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial err:%v\n", err)
+	}
+	defer conn.Close()
+	if err := opts.Apply(conn); err != nil {
+		t.Fatalf("Apply err:%v\n", err)
+	}
+
+	raw, err := conn.(*net.TCPConn).SyscallConn()
+	if err != nil {
+		t.Fatalf("SyscallConn err:%v\n", err)
+	}
+	var noDelay, rcvBuf int
+	raw.Control(func(fd uintptr) {
+		noDelay, _ = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_NODELAY)
+		rcvBuf, _ = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_RCVBUF)
+	})
+	if noDelay != 0 {
+		t.Fatalf("TCP_NODELAY is %d, want 0\n", noDelay)
+	}
+	if rcvBuf < 65536 {
+		t.Fatalf("SO_RCVBUF is %d, want >= 65536\n", rcvBuf)
+	}
+}
+
+func TestConnInfo(t *testing.T) {
+	proto, err := protocol.NewProtocol("binary", `{"fixlen":{}}`)
+	if err != nil {
+		t.Fatalf("New protocol err:%v\n", err)
+	}
+	if _, err := server.NewServer("tcpServer", `{"addr":"127.0.0.1:0","linger":"soon"}`, proto, nil); err == nil {
+		t.Fatalf("invalid linger should fail\n")
+	}
+
+	infos := make(chan server.ConnInfo, 2)
+	handler := server.HandlerFunc(func(session *server.Session) {
+		infos <- session.ConnInfo()
+		session.Close()
+	})
+	before := time.Now()
+	srv, err := server.NewServer("tcpServer", `{"addr":"127.0.0.1:0","noDelay":"true","keepAlive":"30s","writeBuffer":"65536"}`, proto, handler)
+	if err != nil {
+		t.Fatalf("New server err:%v\n", err)
+	}
+	go srv.Run()
+	defer srv.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	cli, err := server.NewClient("tcpClient", `{"addr":"`+serverAddr(srv)+`","noDelay":"false","linger":"0"}`, proto, handler)
+	if err != nil {
+		t.Fatalf("New client err:%v\n", err)
+	}
+	go cli.Run()
+	defer cli.Close()
+
+	var got []server.ConnInfo
+	for i := 0; i < 2; i++ {
+		select {
+		case info := <-infos:
+			got = append(got, info)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("did not get conn info\n")
+		}
+	}
+	for _, info := range got {
+		if info.Transport != "tcp" || info.TLS != nil {
+			t.Fatalf("unexpected conn info %+v\n", info)
+		}
+		if info.LocalAddr == nil || info.RemoteAddr == nil {
+			t.Fatalf("missing addresses in %+v\n", info)
+		}
+		if info.ConnectedAt.Before(before) || info.ConnectedAt.After(time.Now()) {
+			t.Fatalf("ConnectedAt %v out of range\n", info.ConnectedAt)
+		}
+	}
+	//两端看到的地址正好相反
+	if got[0].LocalAddr.String() != got[1].RemoteAddr.String() || got[0].RemoteAddr.String() != got[1].LocalAddr.String() {
+		t.Fatalf("addresses do not match: %+v %+v\n", got[0], got[1])
+	}
+}
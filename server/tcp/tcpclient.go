@@ -1,6 +1,7 @@
 package tcpserver
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"log"
@@ -22,6 +23,9 @@ type tcpClient struct {
 	addr         string
 	timeout      int
 	sendChanSize int
+	sessionOpts  server.SessionOptions
+	tlsConfig    *tls.Config//"tls":"true"时启用
+	sockOpts     server.SocketOptions
 	handler      server.Handler
 	protocol     protocol.Protocol
 	sm           *server.SessionManager
@@ -50,6 +54,22 @@ func (c *tcpClient) Init(config string, protocol protocol.Protocol, handler serv
 	c.timeout,_      = strconv.Atoi(cfg["timeout"])
 	c.sendChanSize,_ = strconv.Atoi(cfg["sendChanSize"])
 	c.connNum,_= strconv.Atoi(cfg["connNum"])
+	var err error
+	if c.sessionOpts,err = server.ParseSessionOptions(cfg); err != nil {
+		return err
+	}
+	if c.sockOpts,err = server.ParseSocketOptions(cfg); err != nil {
+		return err
+	}
+	c.tlsConfig = nil
+	if enable,_ := strconv.ParseBool(cfg["tls"]); enable {
+		if c.tlsConfig,err = server.ParseClientTLSConfig(cfg); err != nil {
+			return err
+		}
+		if c.tlsConfig.ServerName == "" {
+			c.tlsConfig.ServerName,_,_ = net.SplitHostPort(c.addr)
+		}
+	}
 	c.handler  = handler
 	c.protocol = protocol
 	c.sm       = sm
@@ -60,19 +80,49 @@ func (c *tcpClient) Run() {
 	for i:=0; i<c.connNum; i++ {
 		c.wg.Add(1)
 		go func(){
+			defer c.wg.Done()
 			conn,err := c.dial()
 			if err != nil {
-				log.Fatalf("Client dial got a err:%v\n",err)
+				log.Printf("Client dial got a err:%v\n",err)
+				return
+			}
+			session,err := c.newSession(conn)
+			if err != nil {
+				log.Printf("Client new session got a err:%v\n",err)
+				return
 			}
-			codec,_ := c.protocol.NewCodec(conn)
-			session := c.sm.NewSession(codec, c.sendChanSize)
 			c.handler.Handle(session)
-			c.wg.Done()
 		}()
 	}
 	c.wg.Wait()
 }
 
+//设置socket选项，配置了tls时先完成握手，再创建Session
+func (c *tcpClient) newSession(conn net.Conn) (*server.Session, error) {
+	if err := c.sockOpts.Apply(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	var state *tls.ConnectionState
+	if c.tlsConfig != nil {
+		tlsConn := tls.Client(conn, c.tlsConfig)
+		var err error
+		if state,err = handshake(tlsConn, time.Duration(c.timeout)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+	codec,_ := c.protocol.NewCodec(conn)
+	session := c.sm.NewSessionWithInfo(codec, c.sessionOpts, server.ConnInfo{
+		Transport:  "tcp",
+		LocalAddr:  conn.LocalAddr(),
+		RemoteAddr: conn.RemoteAddr(),
+		TLS:        state,
+	})
+	return session, nil
+}
+
 func (c *tcpClient) dial() (net.Conn,error) {
 	var netConn net.Conn
 	var err error
@@ -1,6 +1,8 @@
 package tcpserver
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"io"
 	"log"
@@ -11,6 +13,7 @@ import (
 
 	"github.com/gary163/seals/protocol"
 	"github.com/gary163/seals/server"
+	"github.com/gary163/seals/server/proxyproto"
 )
 
 const (
@@ -18,6 +21,7 @@ const (
 	defaultSendChanSize = 1024
 	maxTryTime = 3
 	defaultAddr = "0.0.0.0:0"
+	defaultHandshakeTimeout = 10*time.Second
 )
 
 type tcpServer struct {
@@ -25,9 +29,16 @@ type tcpServer struct {
 	maxConn      int//最大连接数
 	listener     net.Listener
 	sendChanSize int//异步send的buffer个数
+	sessionOpts  server.SessionOptions
+	tlsConfig    *tls.Config//配置了certFile时启用TLS
+	proxyConfig  *proxyproto.Config//配置了proxyProtocol时解析PROXY头
+	sockOpts     server.SocketOptions//noDelay,keepAlive,readBuffer,writeBuffer,linger
+	handshakeTimeout time.Duration
 	protocol     protocol.Protocol
 	handler      server.Handler
 	sm           *server.SessionManager
+	handlers     server.HandlerGroup
+	goingAway    interface{}//优雅关闭时发给每个Session的消息
 }
 
 func init() {
@@ -56,13 +67,41 @@ func (s *tcpServer) Init(config string, protocol protocol.Protocol, handler serv
 	s.maxConn,_      = strconv.Atoi(cfg["maxConn"])
 	s.sendChanSize,_ = strconv.Atoi(cfg["sendChanSize"])
 	s.addr,_         = cfg["addr"]
+	if s.sessionOpts,err = server.ParseSessionOptions(cfg); err != nil {
+		return err
+	}
+	if s.tlsConfig,err = server.ParseServerTLSConfig(cfg); err != nil {
+		return err
+	}
+	if s.proxyConfig,err = proxyproto.ParseConfig(cfg); err != nil {
+		return err
+	}
+	if s.sockOpts,err = server.ParseSocketOptions(cfg); err != nil {
+		return err
+	}
+	s.handshakeTimeout = defaultHandshakeTimeout
+	if v,ok := cfg["tlsHandshakeTimeout"]; ok {
+		if s.handshakeTimeout,err = time.ParseDuration(v); err != nil {
+			return err
+		}
+	}
 	s.protocol = protocol
 	s.handler  = handler
 	s.sm       = sm
 
-	if s.listener,err = net.Listen("tcp", s.addr); err!= nil {
+	//"reusePort":"true"时多个进程可以监听同一端口，热重启时使用父进程传下来的socket
+	reusePort,_ := strconv.ParseBool(cfg["reusePort"])
+	if s.listener,err = server.Listen("tcp", s.addr, reusePort); err != nil {
 		return err
 	}
+	s.listener = s.sockOpts.Listener(s.listener)
+	//PROXY头在TLS握手之前
+	if s.proxyConfig != nil {
+		s.listener = proxyproto.NewListener(s.listener, s.proxyConfig)
+	}
+	if s.tlsConfig != nil {
+		s.listener = tls.NewListener(s.listener, s.tlsConfig)
+	}
 	return nil
 }
 
@@ -70,12 +109,6 @@ func (s *tcpServer) Run() error {
 	tryTime := 0
 	for{
 		conn,err := s.listener.Accept()
-		if s.sm.Len() > int64(s.maxConn) {
-			log.Printf("Too manay connection:%d\n",s.maxConn)
-			conn.Close()
-			continue
-		}
-
 		if err != nil {
 			if ne, ok := err.(net.Error); ok && ne.Temporary() && tryTime < maxTryTime{
 				time.Sleep(50*time.Millisecond)
@@ -85,11 +118,44 @@ func (s *tcpServer) Run() error {
 			if strings.Contains(err.Error(), "use of closed network connection") {
 				return io.EOF
 			}
+			return err
+		}
+
+		if s.sm.Len() > int64(s.maxConn) {
+			log.Printf("Too manay connection:%d\n",s.maxConn)
+			conn.Close()
+			continue
 		}
 
+		//StopGracefully已经开始等待handler，不再处理新连接
+		if !s.handlers.Add() {
+			conn.Close()
+			continue
+		}
 		go func(){
+			defer s.handlers.Done()
+			if pc,ok := conn.(*proxyproto.Conn); ok {
+				if err := pc.Parse(); err != nil {
+					log.Printf("PROXY header from %s err:%v\n",pc.ProxyAddr(),err)
+					conn.Close()
+					return
+				}
+			}
+			var state *tls.ConnectionState
+			if tlsConn,ok := conn.(*tls.Conn); ok {
+				if state,err = handshake(tlsConn, s.handshakeTimeout); err != nil {
+					log.Printf("TLS handshake with %s err:%v\n",conn.RemoteAddr(),err)
+					conn.Close()
+					return
+				}
+			}
 			codec,_ := s.protocol.NewCodec(conn)
-			session := s.sm.NewSession(codec, s.sendChanSize)
+			session := s.sm.NewSessionWithInfo(codec, s.sessionOpts, server.ConnInfo{
+				Transport:  "tcp",
+				LocalAddr:  conn.LocalAddr(),
+				RemoteAddr: conn.RemoteAddr(),
+				TLS:        state,
+			})
 			s.handler.Handle(session)
 		}()
 	}
@@ -101,3 +167,26 @@ func (s *tcpServer) Stop() error {
 	return nil
 }
 
+func (s *tcpServer) SetGoingAway(msg interface{}) {
+	s.goingAway = msg
+}
+
+func (s *tcpServer) StopGracefully(ctx context.Context) (int, error) {
+	s.listener.Close()
+	n := s.sm.Drain(ctx, s.goingAway)
+	return n, s.handlers.Wait(ctx)
+}
+
+
+//在创建Session之前完成握手，这样握手失败的连接不会出现在SessionManager中
+func handshake(conn *tls.Conn, timeout time.Duration) (*tls.ConnectionState, error) {
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+		defer conn.SetDeadline(time.Time{})
+	}
+	if err := conn.Handshake(); err != nil {
+		return nil, err
+	}
+	state := conn.ConnectionState()
+	return &state, nil
+}
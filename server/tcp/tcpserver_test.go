@@ -88,7 +88,7 @@ func RandByte(n int) []byte {
 
 func TcpServerTest(t *testing.T ,sendChanSize int) {
 	cfg := make(map[string]string)
-	cfg["addr"] = "0.0.0.0:55567"
+	cfg["addr"] = "127.0.0.1:0"
 	cfg["sendChanSize"]  = strconv.Itoa(sendChanSize)
 	strCfg,err := json.Marshal(cfg)
 
@@ -107,7 +107,7 @@ func TcpServerTest(t *testing.T ,sendChanSize int) {
 		srv.Run()
 	}()
 
-	client,err := server.NewClient("tcpClient",`{"addr":"127.0.0.1:55567","connNum":"100"}`,proto, &tcpclient{t})
+	client,err := server.NewClient("tcpClient",`{"addr":"`+serverAddr(srv)+`","connNum":"100"}`,proto, &tcpclient{t})
 	if err != nil {
 		t.Fatalf("New Client err:%v\n",err)
 	}
@@ -249,10 +249,8 @@ func (cc *channelClient) Handle(session *server.Session){
 	}
 }
 
-const serverAddr = "0.0.0.0:58567"
-const clientAddr = "127.0.0.1:58567"
-
 func TestChannel(t *testing.T){
+	finishChan = make(chan struct{})
 	waitClient.Add(clientConnNum)
 	proto, err := protocol.NewProtocol("binary",`{"fixlen":{},"bufio":{"readSize":"2048","writeSize":"2048"}}`)
 	if err != nil {
@@ -260,7 +258,7 @@ func TestChannel(t *testing.T){
 	}
 
 	serverConfigMap := make(map[string]string)
-	serverConfigMap["addr"] = serverAddr
+	serverConfigMap["addr"] = "127.0.0.1:0"
 	serverConfigMap["sendChanSize"] = strconv.Itoa(msgNum)
 
 	serverCfgString,_ := json.Marshal(serverConfigMap)
@@ -275,7 +273,7 @@ func TestChannel(t *testing.T){
 	go sendToclient()
 
 	clientConfigMap := make(map[string]string)
-	clientConfigMap["addr"] = clientAddr
+	clientConfigMap["addr"] = serverAddr(srv)
 	clientConfigMap["connNum"] = strconv.Itoa(clientConnNum)
 	clientCfgString,_ := json.Marshal(clientConfigMap)
 	cli, err := server.NewClient("tcpClient",string(clientCfgString), proto, &channelClient{t})
@@ -287,3 +285,7 @@ func TestChannel(t *testing.T){
 	srv.Stop()
 }
 
+//测试服务都监听127.0.0.1:0，由系统分配端口，这里取回实际监听的地址
+func serverAddr(srv server.Server) string {
+	return srv.(*tcpServer).listener.Addr().String()
+}
@@ -0,0 +1,157 @@
+package tcpserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gary163/seals/protocol"
+	"github.com/gary163/seals/server"
+)
+
+//签发证书，parent为nil时生成自签名的CA
+func issueCert(t *testing.T, cn string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return cert, key
+}
+
+func writeCert(t *testing.T, dir, name string, cert *x509.Certificate, key *ecdsa.PrivateKey) (string, string) {
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	if err := ioutil.WriteFile(certFile, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if key != nil {
+		der, _ := x509.MarshalECPrivateKey(key)
+		keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+		if err := ioutil.WriteFile(keyFile, keyPEM, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return certFile, keyFile
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "seals-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca, caKey := issueCert(t, "seals-ca", nil, nil)
+	caFile, _ := writeCert(t, dir, "ca", ca, nil)
+	cert, key := issueCert(t, "server-1", ca, caKey)
+	serverCert, serverKey := writeCert(t, dir, "server", cert, key)
+	cert, key = issueCert(t, "client-a", ca, caKey)
+	clientCert, clientKey := writeCert(t, dir, "client", cert, key)
+
+	proto, err := protocol.NewProtocol("binary", `{"fixlen":{}}`)
+	if err != nil {
+		t.Fatalf("New protocol err:%v\n", err)
+	}
+
+	subjects := make(chan string, 10)
+	srvConfig := `{"addr":"127.0.0.1:0","certFile":"` + serverCert + `","keyFile":"` + serverKey + `","caFile":"` + caFile +
+		`","clientAuth":"requireAndVerify","minVersion":"1.2","certReloadInterval":"0s"}`
+	srv, err := server.NewServer("tcpServer", srvConfig, proto, server.HandlerFunc(func(session *server.Session) {
+		defer session.Close()
+		if subject, ok := session.PeerSubject(); ok {
+			subjects <- subject.CommonName
+		}
+		for {
+			msg, err := session.Receive()
+			if err != nil {
+				return
+			}
+			session.Send(msg)
+		}
+	}))
+	if err != nil {
+		t.Fatalf("New server err:%v\n", err)
+	}
+	go srv.Run()
+	defer srv.Stop()
+
+	//返回客户端看到的服务端证书CN
+	dial := func() string {
+		serverCN := make(chan string, 1)
+		cliConfig := `{"addr":"` + serverAddr(srv) + `","tls":"true","caFile":"` + caFile + `","certFile":"` + clientCert + `","keyFile":"` + clientKey + `"}`
+		cli, err := server.NewClient("tcpClient", cliConfig, proto, server.HandlerFunc(func(session *server.Session) {
+			defer session.Close()
+			if state, ok := session.TLSState(); ok {
+				serverCN <- state.PeerCertificates[0].Subject.CommonName
+			}
+			session.Send([]byte("hello"))
+			if recv, err := session.Receive(); err != nil || string(recv.([]byte)) != "hello" {
+				t.Errorf("recv %q err:%v\n", recv, err)
+			}
+		}))
+		if err != nil {
+			t.Fatalf("New client err:%v\n", err)
+		}
+		cli.Run()
+		if got := <-subjects; got != "client-a" {
+			t.Fatalf("peer subject %q, want client-a\n", got)
+		}
+		return <-serverCN
+	}
+
+	if cn := dial(); cn != "server-1" {
+		t.Fatalf("server cert %q, want server-1\n", cn)
+	}
+
+	//替换证书文件后新连接使用新证书
+	cert, key = issueCert(t, "server-2", ca, caKey)
+	writeCert(t, dir, "server", cert, key)
+	if cn := dial(); cn != "server-2" {
+		t.Fatalf("server cert %q, want server-2 after reload\n", cn)
+	}
+
+	//没有客户端证书的连接被拒绝
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	conn, err := tls.Dial("tcp", serverAddr(srv), &tls.Config{RootCAs: pool})
+	if err == nil {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, err = conn.Read(make([]byte, 1))
+		conn.Close()
+	}
+	if err == nil {
+		t.Fatal("connection without client cert accepted")
+	}
+}
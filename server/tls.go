@@ -0,0 +1,269 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultCertReloadInterval = 10 * time.Second
+
+//TLS相关的配置项，服务端和客户端共用：
+//certFile,keyFile 本端证书，文件更新后自动重新加载，不需要重启
+//certReloadInterval 检查证书文件是否更新的最小间隔，默认10s，"0s"表示每次握手都检查
+//caFile 校验对端证书用的CA，服务端用于校验客户端证书，客户端用于校验服务端证书
+//clientAuth 服务端校验客户端证书的方式：none,request,require,verifyIfGiven,requireAndVerify
+//minVersion 最低TLS版本：1.0,1.1,1.2,1.3
+//cipherSuites 逗号分隔的加密套件名，如TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256
+//serverName 客户端SNI及校验服务端证书用的主机名
+//insecureSkipVerify 客户端不校验服务端证书，只用于测试
+
+//服务端的TLS配置，没有配置certFile时返回nil，表示不启用TLS
+func ParseServerTLSConfig(cfg map[string]string) (*tls.Config, error) {
+	if cfg["certFile"] == "" && cfg["keyFile"] == "" {
+		return nil, nil
+	}
+	config, err := parseTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	reloader, err := newCertReloader(cfg)
+	if err != nil {
+		return nil, err
+	}
+	config.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return reloader.certificate()
+	}
+
+	if config.ClientCAs, err = loadCertPool(cfg["caFile"]); err != nil {
+		return nil, err
+	}
+	if config.ClientAuth, err = parseClientAuth(cfg["clientAuth"]); err != nil {
+		return nil, err
+	}
+	if config.ClientAuth >= tls.VerifyClientCertIfGiven && config.ClientCAs == nil {
+		return nil, fmt.Errorf("TLS:clientAuth %q needs caFile", cfg["clientAuth"])
+	}
+	return config, nil
+}
+
+//客户端的TLS配置，有证书时用于双向认证
+func ParseClientTLSConfig(cfg map[string]string) (*tls.Config, error) {
+	config, err := parseTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	config.ServerName = cfg["serverName"]
+	if v, ok := cfg["insecureSkipVerify"]; ok {
+		if config.InsecureSkipVerify, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("TLS:insecureSkipVerify %q is invalid", v)
+		}
+	}
+	if config.RootCAs, err = loadCertPool(cfg["caFile"]); err != nil {
+		return nil, err
+	}
+
+	if cfg["certFile"] != "" || cfg["keyFile"] != "" {
+		reloader, err := newCertReloader(cfg)
+		if err != nil {
+			return nil, err
+		}
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return reloader.certificate()
+		}
+	}
+	return config, nil
+}
+
+//go.mod要求兼容Go 1.12，没有tls.CipherSuites()，按名字手动列出crypto/tls支持的套件
+//CHACHA20套件同时接受带_SHA256后缀的标准名
+var cipherSuites = map[string]uint16{
+	"TLS_RSA_WITH_RC4_128_SHA":                      tls.TLS_RSA_WITH_RC4_128_SHA,
+	"TLS_RSA_WITH_3DES_EDE_CBC_SHA":                 tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA,
+	"TLS_RSA_WITH_AES_128_CBC_SHA":                  tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+	"TLS_RSA_WITH_AES_256_CBC_SHA":                  tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+	"TLS_RSA_WITH_AES_128_CBC_SHA256":               tls.TLS_RSA_WITH_AES_128_CBC_SHA256,
+	"TLS_RSA_WITH_AES_128_GCM_SHA256":               tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+	"TLS_RSA_WITH_AES_256_GCM_SHA384":               tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_ECDSA_WITH_RC4_128_SHA":              tls.TLS_ECDHE_ECDSA_WITH_RC4_128_SHA,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA":          tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA":          tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_RC4_128_SHA":                tls.TLS_ECDHE_RSA_WITH_RC4_128_SHA,
+	"TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA":           tls.TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA":            tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA":            tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256":       tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256,
+	"TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256":         tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256,
+	"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256":         tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256":       tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384":         tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384":       tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305":          tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+	"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256":   tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+	"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305":        tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+	"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256": tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+	"TLS_AES_128_GCM_SHA256":                        tls.TLS_AES_128_GCM_SHA256,
+	"TLS_AES_256_GCM_SHA384":                        tls.TLS_AES_256_GCM_SHA384,
+	"TLS_CHACHA20_POLY1305_SHA256":                  tls.TLS_CHACHA20_POLY1305_SHA256,
+}
+
+func parseTLSConfig(cfg map[string]string) (*tls.Config, error) {
+	config := &tls.Config{}
+
+	if v := cfg["minVersion"]; v != "" {
+		versions := map[string]uint16{
+			"1.0": tls.VersionTLS10,
+			"1.1": tls.VersionTLS11,
+			"1.2": tls.VersionTLS12,
+			"1.3": tls.VersionTLS13,
+		}
+		version, ok := versions[v]
+		if !ok {
+			return nil, fmt.Errorf("TLS:minVersion %q is invalid", v)
+		}
+		config.MinVersion = version
+	}
+
+	if v := cfg["cipherSuites"]; v != "" {
+		for _, name := range strings.Split(v, ",") {
+			id, ok := cipherSuites[strings.TrimSpace(name)]
+			if !ok {
+				return nil, fmt.Errorf("TLS:unknown cipher suite %q", name)
+			}
+			config.CipherSuites = append(config.CipherSuites, id)
+		}
+	}
+	return config, nil
+}
+
+func parseClientAuth(s string) (tls.ClientAuthType, error) {
+	switch s {
+	case "", "none":
+		return tls.NoClientCert, nil
+	case "request":
+		return tls.RequestClientCert, nil
+	case "require":
+		return tls.RequireAnyClientCert, nil
+	case "verifyIfGiven":
+		return tls.VerifyClientCertIfGiven, nil
+	case "requireAndVerify":
+		return tls.RequireAndVerifyClientCert, nil
+	}
+	return tls.NoClientCert, fmt.Errorf("TLS:clientAuth %q is invalid", s)
+}
+
+//caFile为空时返回nil，使用系统CA
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	if caFile == "" {
+		return nil, nil
+	}
+	pem, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("TLS:no certificate found in %s", caFile)
+	}
+	return pool, nil
+}
+
+//握手时按需检查证书文件的修改时间，有变化则重新加载，加载失败时继续使用旧证书
+type certReloader struct {
+	certFile  string
+	keyFile   string
+	interval  time.Duration
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+func newCertReloader(cfg map[string]string) (*certReloader, error) {
+	r := &certReloader{certFile: cfg["certFile"], keyFile: cfg["keyFile"]}
+	r.interval = defaultCertReloadInterval
+	if v, ok := cfg["certReloadInterval"]; ok {
+		var err error
+		if r.interval, err = time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("TLS:certReloadInterval %q is invalid", v)
+		}
+	}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) reload() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.cert = &cert
+	r.modTime = modTime
+	return nil
+}
+
+func (r *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{r.certFile, r.keyFile} {
+		fi, err := os.Stat(file)
+		if err != nil {
+			return latest, err
+		}
+		if fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (r *certReloader) certificate() (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	if now.Sub(r.checkedAt) < r.interval {
+		return r.cert, nil
+	}
+	r.checkedAt = now
+	if modTime, err := r.latestModTime(); err == nil && !modTime.Equal(r.modTime) {
+		r.reload()
+	}
+	return r.cert, nil
+}
+
+type tlsStateKey struct{}
+
+//由NewSessionWithInfo根据ConnInfo设置
+func (s *Session) setTLSState(state tls.ConnectionState) {
+	s.SetAttr(tlsStateKey{}, state)
+}
+
+//非TLS连接ok为false
+func (s *Session) TLSState() (tls.ConnectionState, bool) {
+	value, _ := s.Attr(tlsStateKey{})
+	state, ok := value.(tls.ConnectionState)
+	return state, ok
+}
+
+//对端证书通过校验时返回证书的Subject，可用于授权
+func (s *Session) PeerSubject() (pkix.Name, bool) {
+	state, ok := s.TLSState()
+	if !ok || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return pkix.Name{}, false
+	}
+	return state.VerifiedChains[0][0].Subject, true
+}
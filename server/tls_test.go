@@ -0,0 +1,28 @@
+package server
+
+import (
+	"crypto/tls"
+	"testing"
+)
+
+func TestParseCipherSuites(t *testing.T) {
+	config, err := parseTLSConfig(map[string]string{
+		"cipherSuites": "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256",
+	})
+	if err != nil {
+		t.Fatalf("parseTLSConfig err:%v\n", err)
+	}
+	want := []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305}
+	if len(config.CipherSuites) != len(want) {
+		t.Fatalf("CipherSuites = %v, want %v\n", config.CipherSuites, want)
+	}
+	for i := range want {
+		if config.CipherSuites[i] != want[i] {
+			t.Fatalf("CipherSuites = %v, want %v\n", config.CipherSuites, want)
+		}
+	}
+
+	if _, err := parseTLSConfig(map[string]string{"cipherSuites": "TLS_NULL_WITH_NULL_NULL"}); err == nil {
+		t.Fatal("unknown cipher suite should fail")
+	}
+}
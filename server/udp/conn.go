@@ -0,0 +1,101 @@
+package udpserver
+
+import (
+	"io"
+	"net"
+	"sync"
+)
+
+const maxDatagramSize = 65535
+
+//服务端按远端地址拆分出来的虚拟连接，一个数据报对应一次Write
+//一次Read最多返回一个数据报，读不完的部分留给下次Read，不会和下一个数据报拼在一起
+type udpConn struct {
+	server    *udpServer
+	addr      *net.UDPAddr
+	recvChan  chan []byte
+	data      []byte //当前数据报未读完的部分
+	closeChan chan struct{}
+	closeOnce sync.Once
+}
+
+func newUDPConn(server *udpServer, addr *net.UDPAddr, recvChanSize int) *udpConn {
+	return &udpConn{
+		server:    server,
+		addr:      addr,
+		recvChan:  make(chan []byte, recvChanSize),
+		closeChan: make(chan struct{}),
+	}
+}
+
+//投递一个数据报，接收队列满时丢弃
+func (c *udpConn) deliver(datagram []byte) {
+	select {
+	case c.recvChan <- datagram:
+	case <-c.closeChan:
+	default:
+	}
+}
+
+func (c *udpConn) Read(p []byte) (int, error) {
+	if len(c.data) == 0 {
+		select {
+		case c.data = <-c.recvChan:
+		case <-c.closeChan:
+			return 0, io.EOF
+		}
+	}
+	n := copy(p, c.data)
+	c.data = c.data[n:]
+	return n, nil
+}
+
+func (c *udpConn) Write(p []byte) (int, error) {
+	select {
+	case <-c.closeChan:
+		return 0, io.ErrClosedPipe
+	default:
+	}
+	return c.server.conn.WriteToUDP(p, c.addr)
+}
+
+func (c *udpConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closeChan)
+		c.server.remove(c.addr.String())
+	})
+	return nil
+}
+
+func (c *udpConn) LocalAddr() net.Addr {
+	return c.server.conn.LocalAddr()
+}
+
+func (c *udpConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+//客户端连接，UDP的Read一次必须读完整个数据报，这里先读到缓冲区再分次交给codec
+//和udpConn一样，一次Read最多返回一个数据报
+type datagramConn struct {
+	*net.UDPConn
+	buf  []byte
+	data []byte
+}
+
+func newDatagramConn(conn *net.UDPConn) *datagramConn {
+	return &datagramConn{UDPConn: conn, buf: make([]byte, maxDatagramSize)}
+}
+
+func (c *datagramConn) Read(p []byte) (int, error) {
+	for len(c.data) == 0 {
+		n, err := c.UDPConn.Read(c.buf)
+		if err != nil {
+			return 0, err
+		}
+		c.data = c.buf[:n]
+	}
+	n := copy(p, c.data)
+	c.data = c.data[n:]
+	return n, nil
+}
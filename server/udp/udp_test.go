@@ -0,0 +1,169 @@
+package udpserver
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gary163/seals/protocol"
+	_ "github.com/gary163/seals/protocol/binary"
+	_ "github.com/gary163/seals/protocol/json"
+	"github.com/gary163/seals/server"
+)
+
+func randBytes(n int) []byte {
+	b := make([]byte, rand.Intn(n)+1)
+	rand.Read(b)
+	return b
+}
+
+//一次Read最多返回一个数据报，读不完的部分留给下次Read
+func TestUDPConnRead(t *testing.T) {
+	conn := newUDPConn(nil, nil, 4)
+	conn.deliver([]byte("first"))
+	conn.deliver([]byte("second"))
+	conn.deliver([]byte("third"))
+
+	buf := make([]byte, 64)
+	if n, err := conn.Read(buf); err != nil || string(buf[:n]) != "first" {
+		t.Fatalf("Read = %q err:%v, want one datagram\n", buf[:n], err)
+	}
+	if n, _ := conn.Read(buf[:3]); string(buf[:n]) != "sec" {
+		t.Fatalf("short Read = %q\n", buf[:n])
+	}
+	if n, _ := conn.Read(buf); string(buf[:n]) != "ond" {
+		t.Fatalf("Read after a short read = %q, want the rest of the datagram\n", buf[:n])
+	}
+	if n, _ := conn.Read(buf); string(buf[:n]) != "third" {
+		t.Fatalf("Read = %q, want the next datagram\n", buf[:n])
+	}
+}
+
+func TestUDPEcho(t *testing.T) {
+	proto, err := protocol.NewProtocol("binary", `{"fixlen":{},"bufio":{"readSize":"2048","writeSize":"2048"}}`)
+	if err != nil {
+		t.Fatalf("New protocol err:%v\n", err)
+	}
+
+	sm := server.NewSessionManager()
+	closed := make(chan error, 10)
+	sm.OnSessionClosed(func(session *server.Session, reason error) {
+		closed <- reason
+	})
+	srv, err := server.NewServerWithManager("udpServer", `{"addr":"127.0.0.1:0","readIdleTimeout":"200ms"}`, proto, server.HandlerFunc(func(session *server.Session) {
+		defer session.Close()
+		for {
+			msg, err := session.Receive()
+			if err != nil {
+				return
+			}
+			session.Send(msg)
+		}
+	}), sm)
+	if err != nil {
+		t.Fatalf("New server err:%v\n", err)
+	}
+	go srv.Run()
+	defer srv.Stop()
+
+	cli, err := server.NewClient("udpClient", `{"addr":"`+srv.(*udpServer).conn.LocalAddr().String()+`","connNum":"10"}`, proto, server.HandlerFunc(func(session *server.Session) {
+		defer session.Close()
+		for i := 0; i < 50; i++ {
+			msg := randBytes(1000)
+			if err := session.Send(msg); err != nil {
+				t.Errorf("Send err:%v\n", err)
+				return
+			}
+			recv, err := session.Receive()
+			if err != nil {
+				t.Errorf("Receive err:%v\n", err)
+				return
+			}
+			if !bytes.Equal(msg, recv.([]byte)) {
+				t.Errorf("recv msg not equal send msg\n")
+			}
+		}
+	}))
+	if err != nil {
+		t.Fatalf("New client err:%v\n", err)
+	}
+	cli.Run()
+
+	//客户端关闭后虚拟Session空闲过期
+	for i := 0; i < 10; i++ {
+		select {
+		case reason := <-closed:
+			if reason != server.ReadIdleTimeoutError {
+				t.Fatalf("close reason %v, want ReadIdleTimeoutError\n", reason)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("virtual session not expired")
+		}
+	}
+}
+
+type bigMessage struct {
+	Data string
+}
+
+//不使用bufio时，fixlen分两次读取的头和包体、json按512字节分块读取都不能丢数据
+func TestUDPStreamCodecs(t *testing.T) {
+	for _, config := range []string{`{"fixlen":{}}`, ``} {
+		proto, err := protocol.NewProtocol("json", config)
+		if err != nil {
+			t.Fatalf("New protocol err:%v\n", err)
+		}
+		proto.Register(&bigMessage{})
+
+		srv, err := server.NewServer("udpServer", `{"addr":"127.0.0.1:0"}`, proto, server.HandlerFunc(func(session *server.Session) {
+			defer session.Close()
+			for {
+				msg, err := session.Receive()
+				if err != nil {
+					return
+				}
+				session.Send(msg)
+			}
+		}))
+		if err != nil {
+			t.Fatalf("New server err:%v\n", err)
+		}
+		go srv.Run()
+		addr := srv.(*udpServer).conn.LocalAddr().String()
+
+		done := make(chan struct{})
+		cli, err := server.NewClient("udpClient", `{"addr":"`+addr+`"}`, proto, server.HandlerFunc(func(session *server.Session) {
+			defer close(done)
+			defer session.Close()
+			for i := 1; i <= 3; i++ {
+				msg := &bigMessage{Data: strings.Repeat("x", 600*i)}
+				if err := session.Send(msg); err != nil {
+					t.Errorf("%q: Send err:%v\n", config, err)
+					return
+				}
+				recv, err := session.Receive()
+				if err != nil {
+					t.Errorf("%q: Receive err:%v\n", config, err)
+					return
+				}
+				if got, ok := recv.(*bigMessage); !ok || got.Data != msg.Data {
+					t.Errorf("%q: recv %#v, want %d bytes\n", config, recv, len(msg.Data))
+					return
+				}
+			}
+		}))
+		if err != nil {
+			t.Fatalf("New client err:%v\n", err)
+		}
+		go cli.Run()
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Errorf("%q: echo timed out\n", config)
+		}
+		cli.Close()
+		srv.Stop()
+	}
+}
@@ -0,0 +1,95 @@
+package udpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/gary163/seals/protocol"
+	"github.com/gary163/seals/server"
+)
+
+const defaultConnNum = 1
+
+//每个连接使用独立的UDP socket，一条消息对应一个数据报
+type udpClient struct {
+	addr        string
+	sessionOpts server.SessionOptions
+	handler     server.Handler
+	protocol    protocol.Protocol
+	sm          *server.SessionManager
+	connNum     int
+	wg          sync.WaitGroup
+}
+
+func (c *udpClient) Init(config string, protocol protocol.Protocol, handler server.Handler, sm *server.SessionManager) error {
+	var cfg map[string]string
+	json.Unmarshal([]byte(config),&cfg)
+
+	if _,ok := cfg["addr"]; !ok {
+		return errors.New("UdpClient:Missing addr parameter")
+	}
+	if _,ok := cfg["sendChanSize"]; !ok {
+		cfg["sendChanSize"] = strconv.Itoa(defaultSendChanSize)
+	}
+	if _,ok := cfg["connNum"]; !ok {
+		cfg["connNum"] = strconv.Itoa(defaultConnNum)
+	}
+
+	c.addr      = cfg["addr"]
+	c.connNum,_ = strconv.Atoi(cfg["connNum"])
+	var err error
+	if c.sessionOpts,err = server.ParseSessionOptions(cfg); err != nil {
+		return err
+	}
+	c.handler  = handler
+	c.protocol = protocol
+	c.sm       = sm
+	return nil
+}
+
+func (c *udpClient) Run() {
+	for i:=0; i<c.connNum; i++ {
+		c.wg.Add(1)
+		go func(){
+			defer c.wg.Done()
+			conn,err := c.dial()
+			if err != nil {
+				log.Printf("Client dial got a err:%v\n",err)
+				return
+			}
+			codec,_ := c.protocol.NewCodec(conn)
+			session := c.sm.NewSessionWithInfo(codec, c.sessionOpts, server.ConnInfo{
+				Transport:  "udp",
+				LocalAddr:  conn.LocalAddr(),
+				RemoteAddr: conn.RemoteAddr(),
+			})
+			c.handler.Handle(session)
+		}()
+	}
+	c.wg.Wait()
+}
+
+func (c *udpClient) dial() (*datagramConn,error) {
+	addr,err := net.ResolveUDPAddr("udp", c.addr)
+	if err != nil {
+		return nil,err
+	}
+	conn,err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		return nil,err
+	}
+	return newDatagramConn(conn),nil
+}
+
+func (c *udpClient) Close() error {
+	c.sm.Destroy()
+	return nil
+}
+
+func init(){
+	server.RegisterClient("udpClient", &udpClient{})
+}
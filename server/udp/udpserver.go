@@ -0,0 +1,156 @@
+package udpserver
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gary163/seals/protocol"
+	"github.com/gary163/seals/server"
+)
+
+const (
+	defaultMaxConn        = 200000
+	defaultSendChanSize   = 1024
+	defaultRecvChanSize   = 64
+	defaultSessionTimeout = "60s"
+	defaultAddr           = "0.0.0.0:0"
+)
+
+//按远端地址把数据报分发到虚拟Session上，一个数据报就是一条消息
+//一次Read最多返回一个数据报，数据报可以分多次读完，fixlen、json等协议可以直接使用
+//虚拟Session在readIdleTimeout(默认60s)内没有收到数据报则过期关闭
+type udpServer struct {
+	addr         string
+	maxConn      int//最大连接数
+	recvChanSize int//每个虚拟连接缓存的数据报个数，满了则丢弃
+	conn         *net.UDPConn
+	sessionOpts  server.SessionOptions
+	protocol     protocol.Protocol
+	handler      server.Handler
+	sm           *server.SessionManager
+	conns        map[string]*udpConn
+	connsMu      sync.Mutex
+}
+
+func init() {
+	server.RegisterServer("udpServer",&udpServer{})
+}
+
+func (s *udpServer) Init(config string, protocol protocol.Protocol, handler server.Handler, sm *server.SessionManager) error {
+	var cfg map[string]string
+	err := json.Unmarshal([]byte(config),&cfg)
+	if err != nil {
+		return err
+	}
+
+	if _,ok := cfg["maxConn"]; !ok {
+		cfg["maxConn"] = strconv.Itoa(defaultMaxConn)
+	}
+
+	if _,ok := cfg["sendChanSize"]; !ok {
+		cfg["sendChanSize"] = strconv.Itoa(defaultSendChanSize)
+	}
+
+	if _,ok := cfg["recvChanSize"]; !ok {
+		cfg["recvChanSize"] = strconv.Itoa(defaultRecvChanSize)
+	}
+
+	if _,ok := cfg["addr"]; !ok {
+		cfg["addr"] = defaultAddr
+	}
+
+	_,readIdle := cfg["readIdleTimeout"]
+	_,idle := cfg["idleTimeout"]
+	if !readIdle && !idle {
+		cfg["readIdleTimeout"] = defaultSessionTimeout
+	}
+
+	s.maxConn,_      = strconv.Atoi(cfg["maxConn"])
+	s.recvChanSize,_ = strconv.Atoi(cfg["recvChanSize"])
+	s.addr           = cfg["addr"]
+	if s.sessionOpts,err = server.ParseSessionOptions(cfg); err != nil {
+		return err
+	}
+	s.protocol = protocol
+	s.handler  = handler
+	s.sm       = sm
+	s.conns    = make(map[string]*udpConn)
+
+	udpAddr,err := net.ResolveUDPAddr("udp", s.addr)
+	if err != nil {
+		return err
+	}
+	if s.conn,err = net.ListenUDP("udp", udpAddr); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *udpServer) Run() error {
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n,addr,err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			if strings.Contains(err.Error(), "use of closed network connection") {
+				return io.EOF
+			}
+			return err
+		}
+
+		datagram := make([]byte, n)
+		copy(datagram, buf[:n])
+
+		conn := s.getOrCreate(addr)
+		if conn != nil {
+			conn.deliver(datagram)
+		}
+	}
+}
+
+func (s *udpServer) getOrCreate(addr *net.UDPAddr) *udpConn {
+	key := addr.String()
+	s.connsMu.Lock()
+	if conn,ok := s.conns[key]; ok {
+		s.connsMu.Unlock()
+		return conn
+	}
+	if len(s.conns) >= s.maxConn {
+		s.connsMu.Unlock()
+		log.Printf("Too manay connection:%d\n",s.maxConn)
+		return nil
+	}
+	conn := newUDPConn(s, addr, s.recvChanSize)
+	s.conns[key] = conn
+	s.connsMu.Unlock()
+
+	go func(){
+		codec,_ := s.protocol.NewCodec(conn)
+		session := s.sm.NewSessionWithInfo(codec, s.sessionOpts, server.ConnInfo{
+			Transport:  "udp",
+			LocalAddr:  conn.LocalAddr(),
+			RemoteAddr: conn.RemoteAddr(),
+		})
+		s.handler.Handle(session)
+	}()
+	return conn
+}
+
+func (s *udpServer) remove(key string) {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+	delete(s.conns, key)
+}
+
+func (s *udpServer) Stop() error {
+	s.conn.Close()
+	s.sm.Destroy()
+	return nil
+}
@@ -0,0 +1,24 @@
+package unixserver
+
+import (
+	"github.com/gary163/seals/server"
+)
+
+//对端进程的凭证，由内核在连接建立时记录，不能伪造
+type PeerCred struct {
+	Pid int32
+	Uid uint32
+	Gid uint32
+}
+
+type peerCredKey struct{}
+
+//unixServer配置了"peerCred":"true"时可以取到客户端进程的凭证
+func SessionPeerCred(session *server.Session) (PeerCred, bool) {
+	value, ok := session.Attr(peerCredKey{})
+	if !ok {
+		return PeerCred{}, false
+	}
+	cred, ok := value.(PeerCred)
+	return cred, ok
+}
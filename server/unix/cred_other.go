@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package unixserver
+
+import (
+	"errors"
+	"net"
+)
+
+func readPeerCred(conn *net.UnixConn) (PeerCred, error) {
+	return PeerCred{}, errors.New("UnixServer:peer credentials are only supported on linux")
+}
@@ -0,0 +1,103 @@
+package unixserver
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gary163/seals/protocol"
+	_ "github.com/gary163/seals/protocol/binary"
+	"github.com/gary163/seals/server"
+)
+
+func TestUnixEcho(t *testing.T) {
+	dir, err := ioutil.TempDir("", "seals")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "echo.sock")
+
+	//模拟进程异常退出后遗留的socket文件
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.SetUnlinkOnClose(false)
+	stale.Close()
+
+	proto, err := protocol.NewProtocol("binary", `{"fixlen":{}}`)
+	if err != nil {
+		t.Fatalf("New protocol err:%v\n", err)
+	}
+
+	//OnSessionCreated钩子中已经能取到对端凭证
+	creds := make(chan PeerCred, 10)
+	sm := server.NewSessionManager()
+	sm.OnSessionCreated(func(session *server.Session) {
+		if cred, ok := SessionPeerCred(session); ok {
+			creds <- cred
+		}
+	})
+	srv, err := server.NewServerWithManager("unixServer", `{"path":"`+path+`","perm":"0600","peerCred":"true"}`, proto, server.HandlerFunc(func(session *server.Session) {
+		defer session.Close()
+		for {
+			msg, err := session.Receive()
+			if err != nil {
+				return
+			}
+			session.Send(msg)
+		}
+	}), sm)
+	if err != nil {
+		t.Fatalf("New server err:%v\n", err)
+	}
+	go srv.Run()
+	defer srv.Stop()
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Fatalf("socket perm %o, want 600\n", fi.Mode().Perm())
+	}
+
+	cli, err := server.NewClient("unixClient", `{"path":"`+path+`","connNum":"5"}`, proto, server.HandlerFunc(func(session *server.Session) {
+		defer session.Close()
+		for i := 0; i < 50; i++ {
+			msg := make([]byte, rand.Intn(1000)+1)
+			rand.Read(msg)
+			if err := session.Send(msg); err != nil {
+				t.Errorf("Send err:%v\n", err)
+				return
+			}
+			recv, err := session.Receive()
+			if err != nil {
+				t.Errorf("Receive err:%v\n", err)
+				return
+			}
+			if !bytes.Equal(msg, recv.([]byte)) {
+				t.Errorf("recv msg not equal send msg\n")
+			}
+		}
+	}))
+	if err != nil {
+		t.Fatalf("New client err:%v\n", err)
+	}
+	cli.Run()
+
+	var cred PeerCred
+	select {
+	case cred = <-creds:
+	default:
+		t.Fatal("peer cred not set before OnSessionCreated")
+	}
+	if cred.Pid != int32(os.Getpid()) || cred.Uid != uint32(os.Getuid()) {
+		t.Fatalf("unexpected peer cred %+v\n", cred)
+	}
+}
@@ -0,0 +1,112 @@
+package unixserver
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gary163/seals/protocol"
+	"github.com/gary163/seals/server"
+)
+
+const (
+	defaultConnNum    = 1
+	defaultMaxTryTime = 3
+)
+
+type unixClient struct {
+	path         string
+	timeout      int
+	sendChanSize int
+	sessionOpts  server.SessionOptions
+	handler      server.Handler
+	protocol     protocol.Protocol
+	sm           *server.SessionManager
+	connNum      int
+	wg           sync.WaitGroup
+}
+
+func (c *unixClient) Init(config string, protocol protocol.Protocol, handler server.Handler, sm *server.SessionManager) error {
+	var cfg map[string]string
+	json.Unmarshal([]byte(config),&cfg)
+
+	if _,ok := cfg["path"]; !ok {
+		return errors.New("UnixClient:Missing path parameter")
+	}
+	if _,ok := cfg["timeout"]; !ok {
+		cfg["timeout"] = strconv.Itoa(0)
+	}
+	if _,ok := cfg["sendChanSize"]; !ok {
+		cfg["sendChanSize"] = strconv.Itoa(defaultSendChanSize)
+	}
+	if _,ok := cfg["connNum"]; !ok {
+		cfg["connNum"] = strconv.Itoa(defaultConnNum)
+	}
+
+	c.path           = cfg["path"]
+	c.timeout,_      = strconv.Atoi(cfg["timeout"])
+	c.sendChanSize,_ = strconv.Atoi(cfg["sendChanSize"])
+	c.connNum,_      = strconv.Atoi(cfg["connNum"])
+	var err error
+	if c.sessionOpts,err = server.ParseSessionOptions(cfg); err != nil {
+		return err
+	}
+	c.handler  = handler
+	c.protocol = protocol
+	c.sm       = sm
+	return nil
+}
+
+func (c *unixClient) Run() {
+	for i:=0; i<c.connNum; i++ {
+		c.wg.Add(1)
+		go func(){
+			defer c.wg.Done()
+			conn,err := c.dial()
+			if err != nil {
+				log.Printf("Client dial got a err:%v\n",err)
+				return
+			}
+			codec,_ := c.protocol.NewCodec(conn)
+			session := c.sm.NewSessionWithInfo(codec, c.sessionOpts, server.ConnInfo{
+				Transport:  "unix",
+				LocalAddr:  conn.LocalAddr(),
+				RemoteAddr: conn.RemoteAddr(),
+			})
+			c.handler.Handle(session)
+		}()
+	}
+	c.wg.Wait()
+}
+
+func (c *unixClient) dial() (net.Conn,error) {
+	var netConn net.Conn
+	var err error
+	tryConnTime := 0
+	for{
+		if c.timeout > 0 {
+			netConn,err = net.DialTimeout("unix",c.path,time.Duration(c.timeout))
+		}else{
+			netConn,err = net.Dial("unix",c.path)
+		}
+		if err == nil || tryConnTime > defaultMaxTryTime {
+			break
+		}
+		time.Sleep(50*time.Millisecond)
+		tryConnTime++
+	}
+	return netConn,err
+}
+
+func (c *unixClient) Close() error {
+	c.sm.Destroy()
+	return nil
+}
+
+func init(){
+	server.RegisterClient("unixClient", &unixClient{})
+}
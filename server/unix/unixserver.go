@@ -0,0 +1,188 @@
+package unixserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gary163/seals/protocol"
+	"github.com/gary163/seals/server"
+)
+
+const (
+	defaultMaxConn = 200000
+	defaultSendChanSize = 1024
+	maxTryTime = 3
+)
+
+//同一主机上的进程间通信，配置与tcpServer一致，addr换成path
+type unixServer struct {
+	path         string
+	perm         os.FileMode//socket文件权限，为0时不修改
+	maxConn      int//最大连接数
+	peerCred     bool//是否读取对端进程的凭证
+	listener     *net.UnixListener
+	sendChanSize int//异步send的buffer个数
+	sessionOpts  server.SessionOptions
+	protocol     protocol.Protocol
+	handler      server.Handler
+	sm           *server.SessionManager
+	handlers     server.HandlerGroup
+	goingAway    interface{}//优雅关闭时发给每个Session的消息
+}
+
+func init() {
+	server.RegisterServer("unixServer",&unixServer{})
+}
+
+func (s *unixServer) Init(config string, protocol protocol.Protocol, handler server.Handler, sm *server.SessionManager) error {
+	var cfg map[string]string
+	err := json.Unmarshal([]byte(config),&cfg)
+	if err != nil {
+		return err
+	}
+
+	if _,ok := cfg["path"]; !ok {
+		return errors.New("UnixServer:Missing path parameter")
+	}
+
+	if _,ok := cfg["maxConn"]; !ok {
+		cfg["maxConn"] = strconv.Itoa(defaultMaxConn)
+	}
+
+	if _,ok := cfg["sendChanSize"]; !ok {
+		cfg["sendChanSize"] = strconv.Itoa(defaultSendChanSize)
+	}
+
+	if v,ok := cfg["perm"]; ok {
+		perm,err := strconv.ParseUint(v, 8, 32)
+		if err != nil {
+			return errors.New("UnixServer:perm must be octal like 0660")
+		}
+		s.perm = os.FileMode(perm)
+	}
+
+	if v,ok := cfg["peerCred"]; ok {
+		if s.peerCred,err = strconv.ParseBool(v); err != nil {
+			return errors.New("UnixServer:peerCred must be true or false")
+		}
+	}
+
+	s.maxConn,_      = strconv.Atoi(cfg["maxConn"])
+	s.sendChanSize,_ = strconv.Atoi(cfg["sendChanSize"])
+	s.path           = cfg["path"]
+	if s.sessionOpts,err = server.ParseSessionOptions(cfg); err != nil {
+		return err
+	}
+	s.protocol = protocol
+	s.handler  = handler
+	s.sm       = sm
+
+	if err = removeStale(s.path); err != nil {
+		return err
+	}
+	if s.listener,err = net.ListenUnix("unix", &net.UnixAddr{Name: s.path, Net: "unix"}); err != nil {
+		return err
+	}
+	//Close时删除socket文件
+	s.listener.SetUnlinkOnClose(true)
+	if s.perm != 0 {
+		if err = os.Chmod(s.path, s.perm); err != nil {
+			s.listener.Close()
+			return err
+		}
+	}
+	return nil
+}
+
+//上次进程异常退出会留下socket文件，没有进程在监听时删除它，否则报地址被占用
+func removeStale(path string) error {
+	fi,err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return errors.New("UnixServer:"+path+" exists and is not a socket")
+	}
+	conn,err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		conn.Close()
+		return errors.New("UnixServer:"+path+" is in use")
+	}
+	return os.Remove(path)
+}
+
+func (s *unixServer) Run() error {
+	tryTime := 0
+	for{
+		conn,err := s.listener.AcceptUnix()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() && tryTime < maxTryTime{
+				time.Sleep(50*time.Millisecond)
+				tryTime ++
+				continue
+			}
+			if strings.Contains(err.Error(), "use of closed network connection") {
+				return io.EOF
+			}
+			return err
+		}
+
+		if s.sm.Len() > int64(s.maxConn) {
+			log.Printf("Too manay connection:%d\n",s.maxConn)
+			conn.Close()
+			continue
+		}
+
+		//StopGracefully已经开始等待handler，不再处理新连接
+		if !s.handlers.Add() {
+			conn.Close()
+			continue
+		}
+		go func(){
+			defer s.handlers.Done()
+			info := server.ConnInfo{
+				Transport:  "unix",
+				LocalAddr:  conn.LocalAddr(),
+				RemoteAddr: conn.RemoteAddr(),
+			}
+			//在创建Session前读取凭证，OnSessionCreated钩子中就能取到
+			if s.peerCred {
+				if cred,err := readPeerCred(conn); err == nil {
+					info.Attrs = map[interface{}]interface{}{peerCredKey{}: cred}
+				} else {
+					log.Printf("Read peer credentials err:%v\n",err)
+				}
+			}
+			codec,_ := s.protocol.NewCodec(conn)
+			session := s.sm.NewSessionWithInfo(codec, s.sessionOpts, info)
+			s.handler.Handle(session)
+		}()
+	}
+}
+
+func (s *unixServer) Stop() error {
+	s.listener.Close()
+	s.sm.Destroy()
+	return nil
+}
+
+func (s *unixServer) SetGoingAway(msg interface{}) {
+	s.goingAway = msg
+}
+
+func (s *unixServer) StopGracefully(ctx context.Context) (int, error) {
+	s.listener.Close()
+	n := s.sm.Drain(ctx, s.goingAway)
+	return n, s.handlers.Wait(ctx)
+}
@@ -0,0 +1,130 @@
+package websocket
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gary163/seals/protocol"
+	"github.com/gary163/seals/server"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	defaultConnNum = 1
+	headerPrefix   = "header."
+)
+
+//拨号ws://或wss://地址，得到的Session和tcpClient的用法一致
+type WSClient struct {
+	url          string
+	connNum      int
+	sendChanSize int
+	messageType  int
+	header       http.Header//"header.X-Token":"abc"形式的配置作为握手请求头
+	dialer       *websocket.Dialer
+	sessionOpts  server.SessionOptions
+	handler      server.Handler
+	protocol     protocol.Protocol
+	sm           *server.SessionManager
+	wg           sync.WaitGroup
+}
+
+func init() {
+	server.RegisterClient("websocketClient",&WSClient{})
+}
+
+func (c *WSClient) Init(config string, protocol protocol.Protocol, handler server.Handler, sm *server.SessionManager) error {
+	var cfg map[string]string
+	err := json.Unmarshal([]byte(config),&cfg)
+	if err != nil {
+		return err
+	}
+
+	if _,ok := cfg["url"]; !ok {
+		return errors.New("WSClient:Missing url parameter")
+	}
+	if _,ok := cfg["sendChanSize"]; !ok {
+		cfg["sendChanSize"] = strconv.Itoa(defaultSendChanSize)
+	}
+	if _,ok := cfg["connNum"]; !ok {
+		cfg["connNum"] = strconv.Itoa(defaultConnNum)
+	}
+	if _,ok := cfg["httpTimeout"]; !ok {
+		cfg["httpTimeout"] = strconv.Itoa(int(defaultHttpTimeout * time.Second))
+	}
+
+	var ok bool
+	if c.messageType,ok = parseMessageType(cfg["messageType"]); !ok {
+		return errors.New("WSClient:messageType must be text or binary")
+	}
+
+	c.header = make(http.Header)
+	for key,value := range cfg {
+		if strings.HasPrefix(key, headerPrefix) {
+			c.header.Add(strings.TrimPrefix(key, headerPrefix), value)
+		}
+	}
+
+	tlsConfig,err := server.ParseClientTLSConfig(cfg)
+	if err != nil {
+		return err
+	}
+	httpTimeout,_ := strconv.Atoi(cfg["httpTimeout"])
+	c.dialer = &websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: time.Duration(httpTimeout),
+		Subprotocols:     splitList(cfg["subprotocols"]),
+		TLSClientConfig:  tlsConfig,
+	}
+
+	c.url            = cfg["url"]
+	c.connNum,_      = strconv.Atoi(cfg["connNum"])
+	c.sendChanSize,_ = strconv.Atoi(cfg["sendChanSize"])
+	if c.sessionOpts,err = server.ParseSessionOptions(cfg); err != nil {
+		return err
+	}
+	c.handler  = handler
+	c.protocol = protocol
+	c.sm       = sm
+	return nil
+}
+
+func (c *WSClient) Run() {
+	for i:=0; i<c.connNum; i++ {
+		c.wg.Add(1)
+		go func(){
+			defer c.wg.Done()
+			conn,_,err := c.dialer.Dial(c.url, c.header)
+			if err != nil {
+				log.Printf("Client dial got a err:%v\n",err)
+				return
+			}
+			var state *tls.ConnectionState
+			if tlsConn,ok := conn.UnderlyingConn().(*tls.Conn); ok {
+				cs := tlsConn.ConnectionState()
+				state = &cs
+			}
+			codec,_ := c.protocol.NewCodec(newWSConn(conn, c.messageType))
+			session := c.sm.NewSessionWithInfo(codec, c.sessionOpts, server.ConnInfo{
+				Transport:  "websocket",
+				LocalAddr:  conn.LocalAddr(),
+				RemoteAddr: conn.RemoteAddr(),
+				TLS:        state,
+			})
+			c.handler.Handle(session)
+		}()
+	}
+	c.wg.Wait()
+}
+
+func (c *WSClient) Close() error {
+	c.sm.Destroy()
+	return nil
+}
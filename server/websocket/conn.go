@@ -0,0 +1,102 @@
+package websocket
+
+import (
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const closeTimeout = time.Second
+
+//把websocket连接适配成字节流，每次Write发送一个完整的消息，Read依次读取收到的消息内容
+//这样任意protocol.Protocol都可以直接用在websocket上
+type wsConn struct {
+	conn        *websocket.Conn
+	messageType int
+	reader      io.Reader
+	writeMu     sync.Mutex
+	closeOnce   sync.Once
+}
+
+func newWSConn(conn *websocket.Conn, messageType int) *wsConn {
+	return &wsConn{conn: conn, messageType: messageType}
+}
+
+func (c *wsConn) Read(p []byte) (int, error) {
+	for {
+		if c.reader == nil {
+			_, reader, err := c.conn.NextReader()
+			if err != nil {
+				//对端正常关闭时按EOF处理，和tcp连接一致
+				if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+					return 0, io.EOF
+				}
+				return 0, err
+			}
+			c.reader = reader
+		}
+		n, err := c.reader.Read(p)
+		if err == io.EOF {
+			c.reader = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (c *wsConn) Write(p []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := c.conn.WriteMessage(c.messageType, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+//先发送关闭帧再关闭底层连接
+func (c *wsConn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+		c.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(closeTimeout))
+		err = c.conn.Close()
+	})
+	return err
+}
+
+func (c *wsConn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *wsConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+//"text"或"binary"，默认binary
+func parseMessageType(s string) (int, bool) {
+	switch s {
+	case "", "binary":
+		return websocket.BinaryMessage, true
+	case "text":
+		return websocket.TextMessage, true
+	}
+	return 0, false
+}
+
+//逗号分隔的列表，忽略空项
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -1,29 +1,29 @@
 package websocket
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"io"
 	"log"
 	"net"
 	"net/http"
 	"strconv"
-	"strings"
+	"sync/atomic"
 	"time"
 
-
 	"github.com/gary163/seals/protocol"
 	"github.com/gary163/seals/server"
-	"github.com/gobwas/ws"
+	"github.com/gary163/seals/server/proxyproto"
 	"github.com/gorilla/websocket"
-
 )
 
 const (
 	defaultMaxConn = 200000
 	defaultSendChanSize = 1024
-	maxTryTime = 3
 	defaultAddr = "0.0.0.0:0"
+	defaultPath = "/"
 	defaultHttpTimeout = 5
 )
 
@@ -31,18 +31,24 @@ type WSServer struct {
 	addr         string
 	maxConn      int//最大连接数
 	listener     net.Listener
+	httpServer   *http.Server
 	sendChanSize int//异步send的buffer个数
+	sessionOpts  server.SessionOptions
 	protocol     protocol.Protocol
 	handler      server.Handler
 	wsHandler    *WSHandler
 	sm           *server.SessionManager
 	httpTimeout  time.Duration
-	certFile     string
-	keyFile      string
+	handlers     server.HandlerGroup
+	goingAway    interface{}//优雅关闭时发给每个Session的消息
 }
 
+//处理http请求，只有配置的path才会升级为websocket连接
 type WSHandler struct {
 	maxConn     int
+	conns       int64//当前连接数，包括正在握手的
+	paths       map[string]bool
+	messageType int
 	upgrader    websocket.Upgrader
 	server      *WSServer
 }
@@ -70,110 +76,143 @@ func (s *WSServer) Init(config string, protocol protocol.Protocol, handler serve
 		cfg["addr"] = defaultAddr
 	}
 
+	if _,ok := cfg["path"]; !ok {
+		cfg["path"] = defaultPath
+	}
+
 	if _,ok := cfg["httpTimeout"]; !ok {
 		cfg["httpTimeout"] = strconv.Itoa(int(defaultHttpTimeout * time.Second))
 	}
 
-	if _,ok := cfg["certFile"]; !ok {
-		cfg["certFile"] = ""
+	messageType,ok := parseMessageType(cfg["messageType"])
+	if !ok {
+		return errors.New("WSServer:messageType must be text or binary")
 	}
 
-	if _,ok := cfg["keyFile"]; !ok {
-		cfg["keyFile"] = ""
+	//多个path用逗号分隔
+	paths := make(map[string]bool)
+	for _,path := range splitList(cfg["path"]) {
+		paths[path] = true
 	}
 
 	s.maxConn,_      = strconv.Atoi(cfg["maxConn"])
 	s.sendChanSize,_ = strconv.Atoi(cfg["sendChanSize"])
 	s.addr,_         = cfg["addr"]
 	httpTimeout,_    := strconv.Atoi(cfg["httpTimeout"])
-	s.httpTimeout =  time.Duration(httpTimeout)
-	s.certFile,_      = cfg["certFile"]
-	s.keyFile,_       = cfg["keyFile"]
+	s.httpTimeout    = time.Duration(httpTimeout)
+	if s.sessionOpts,err = server.ParseSessionOptions(cfg); err != nil {
+		return err
+	}
 	s.protocol = protocol
 	s.handler  = handler
 	s.sm       = sm
 
-	listener, err := net.Listen("tcp", s.addr)
-	if s.certFile != "" || s.keyFile != "" {
-		config := &tls.Config{}
-		config.NextProtos = []string{"http/1.1"}
-
-		var err error
-		config.Certificates = make([]tls.Certificate, 1)
-		config.Certificates[0], err = tls.LoadX509KeyPair(s.certFile, s.keyFile)
-		if err != nil {
-			return err
-		}
-
-		s.listener = tls.NewListener(listener, config)
+	//"reusePort":"true"时多个进程可以监听同一端口，热重启时使用父进程传下来的socket
+	reusePort,_ := strconv.ParseBool(cfg["reusePort"])
+	if s.listener,err = server.Listen("tcp", s.addr, reusePort); err != nil {
+		return err
+	}
+	tlsConfig,err := server.ParseServerTLSConfig(cfg)
+	if err != nil {
+		s.listener.Close()
+		return err
+	}
+	proxyConfig,err := proxyproto.ParseConfig(cfg)
+	if err != nil {
+		s.listener.Close()
+		return err
+	}
+	//PROXY头在TLS握手之前，http.Request的RemoteAddr即为真实的客户端地址
+	if proxyConfig != nil {
+		s.listener = proxyproto.NewListener(s.listener, proxyConfig)
+	}
+	if tlsConfig != nil {
+		tlsConfig.NextProtos = []string{"http/1.1"}
+		s.listener = tls.NewListener(s.listener, tlsConfig)
 	}
 
 	s.wsHandler = &WSHandler{
-		maxConn:      s.maxConn,
+		maxConn:     s.maxConn,
+		paths:       paths,
+		messageType: messageType,
 		upgrader: websocket.Upgrader{
 			HandshakeTimeout: s.httpTimeout,
+			Subprotocols:     splitList(cfg["subprotocols"]),//按客户端给出的顺序选第一个支持的
 			CheckOrigin:      func(_ *http.Request) bool { return true },
 		},
 		server:s,
 	}
 
-	httpServer := &http.Server{
+	//升级后的连接已被接管，ReadTimeout和WriteTimeout只作用于握手阶段
+	s.httpServer = &http.Server{
 		Addr:           s.addr,
 		Handler:        s.wsHandler,
 		ReadTimeout:    s.httpTimeout,
 		WriteTimeout:   s.httpTimeout,
 		MaxHeaderBytes: 1024,
 	}
-
-	go httpServer.Serve(listener)
-
 	return nil
 }
 
 func (s *WSServer) Run() error {
-	tryTime := 0
-	for{
-		conn,err := s.listener.Accept()
-		if s.sm.Len() > int64(s.maxConn) {
-			log.Printf("Too manay connection:%d\n",s.maxConn)
-			conn.Close()
-			continue
-		}
-
-		if err != nil {
-			if ne, ok := err.(net.Error); ok && ne.Temporary() && tryTime < maxTryTime{
-				time.Sleep(50*time.Millisecond)
-				tryTime ++
-				continue
-			}
-			if strings.Contains(err.Error(), "use of closed network connection") {
-				return io.EOF
-			}
-		}
-
-		_, err = ws.Upgrade(conn)
-		if err != nil {
-			// handle error
-		}
-
-		go func() {
-			codec,_ := s.protocol.NewCodec(conn)
-			session := s.sm.NewSession(codec, s.sendChanSize)
-			s.handler.Handle(session)
-		}()
-
-
-		go func(){
-			codec,_ := s.protocol.NewCodec(conn)
-			session := s.sm.NewSession(codec, s.sendChanSize)
-			s.handler.Handle(session)
-		}()
+	err := s.httpServer.Serve(s.listener)
+	if err == http.ErrServerClosed {
+		return io.EOF
+	}
+	return err
+}
+
+func (h *WSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.paths[r.URL.Path] {
+		http.NotFound(w, r)
+		return
+	}
+
+	if atomic.AddInt64(&h.conns, 1) > int64(h.maxConn) {
+		atomic.AddInt64(&h.conns, -1)
+		log.Printf("Too manay connection:%d\n",h.maxConn)
+		http.Error(w, "too many connections", http.StatusServiceUnavailable)
+		return
+	}
+	defer atomic.AddInt64(&h.conns, -1)
+
+	s := h.server
+	//StopGracefully已经开始等待handler，不再升级新连接
+	if !s.handlers.Add() {
+		http.Error(w, "server stopping", http.StatusServiceUnavailable)
+		return
+	}
+	defer s.handlers.Done()
+
+	//Upgrade失败时已经给客户端回复了错误
+	conn,err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
 	}
+
+	codec,_ := s.protocol.NewCodec(newWSConn(conn, h.messageType))
+	session := s.sm.NewSessionWithInfo(codec, s.sessionOpts, server.ConnInfo{
+		Transport:  "websocket",
+		LocalAddr:  conn.LocalAddr(),
+		RemoteAddr: conn.RemoteAddr(),
+		TLS:        r.TLS,
+	})
+	s.handler.Handle(session)
 }
 
 func (s *WSServer) Stop() error {
-	s.listener.Close()
+	s.httpServer.Close()
 	s.sm.Destroy()
 	return nil
 }
 
+func (s *WSServer) SetGoingAway(msg interface{}) {
+	s.goingAway = msg
+}
+
+func (s *WSServer) StopGracefully(ctx context.Context) (int, error) {
+	//Shutdown不会等待已升级的连接，它们由Drain处理
+	s.httpServer.Shutdown(ctx)
+	n := s.sm.Drain(ctx, s.goingAway)
+	return n, s.handlers.Wait(ctx)
+}
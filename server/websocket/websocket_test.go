@@ -0,0 +1,175 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/pem"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gary163/seals/protocol"
+	_ "github.com/gary163/seals/protocol/binary"
+	"github.com/gary163/seals/server"
+	"github.com/gorilla/websocket"
+)
+
+func TestWebsocketEcho(t *testing.T) {
+	proto, err := protocol.NewProtocol("binary", `{"fixlen":{}}`)
+	if err != nil {
+		t.Fatalf("New protocol err:%v\n", err)
+	}
+
+	srv, err := server.NewServer("websocketServer", `{"addr":"127.0.0.1:0","path":"/ws,/echo","messageType":"text","maxConn":"5"}`, proto, server.HandlerFunc(func(session *server.Session) {
+		defer session.Close()
+		for {
+			msg, err := session.Receive()
+			if err != nil {
+				return
+			}
+			session.Send(msg)
+		}
+	}))
+	if err != nil {
+		t.Fatalf("New server err:%v\n", err)
+	}
+	go srv.Run()
+	defer srv.Stop()
+	url := "ws://" + srv.(*WSServer).listener.Addr().String()
+
+	if _, resp, err := websocket.DefaultDialer.Dial(url+"/other", nil); err == nil || resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("dial unknown path err:%v, want 404\n", err)
+	}
+
+	var wg sync.WaitGroup
+	conns := make(chan *websocket.Conn, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			conn, _, err := websocket.DefaultDialer.Dial(url+path, nil)
+			if err != nil {
+				t.Errorf("Dial err:%v\n", err)
+				return
+			}
+			conns <- conn
+
+			codec, _ := proto.NewCodec(newWSConn(conn, websocket.TextMessage))
+			for j := 0; j < 20; j++ {
+				msg := make([]byte, rand.Intn(1000)+1)
+				rand.Read(msg)
+				if err := codec.Send(msg); err != nil {
+					t.Errorf("Send err:%v\n", err)
+					return
+				}
+				recv, err := codec.Receive()
+				if err != nil {
+					t.Errorf("Receive err:%v\n", err)
+					return
+				}
+				if !bytes.Equal(msg, recv.([]byte)) {
+					t.Errorf("recv msg not equal send msg\n")
+				}
+			}
+
+			//服务端按配置使用文本帧
+			if err := codec.Send([]byte("x")); err != nil {
+				t.Errorf("Send err:%v\n", err)
+				return
+			}
+			typ, _, err := conn.NextReader()
+			if err != nil || typ != websocket.TextMessage {
+				t.Errorf("message type %d err:%v, want text\n", typ, err)
+			}
+		}([]string{"/ws", "/echo"}[i%2])
+	}
+	wg.Wait()
+
+	//超过maxConn的连接被拒绝
+	if _, resp, err := websocket.DefaultDialer.Dial(url+"/ws", nil); err == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("dial over maxConn err:%v, want 503\n", err)
+	}
+	close(conns)
+	for conn := range conns {
+		conn.Close()
+	}
+}
+
+func TestWebsocketClient(t *testing.T) {
+	proto, err := protocol.NewProtocol("binary", `{"fixlen":{}}`)
+	if err != nil {
+		t.Fatalf("New protocol err:%v\n", err)
+	}
+
+	//wss服务端检查握手请求头和子协议后回显
+	upgrader := websocket.Upgrader{Subprotocols: []string{"seals.v2"}}
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "secret" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		if conn.Subprotocol() != "seals.v2" {
+			t.Errorf("subprotocol %q, want seals.v2\n", conn.Subprotocol())
+		}
+		codec, _ := proto.NewCodec(newWSConn(conn, websocket.BinaryMessage))
+		defer codec.Close()
+		for {
+			msg, err := codec.Receive()
+			if err != nil {
+				return
+			}
+			codec.Send(msg)
+		}
+	}))
+	defer srv.Close()
+
+	caFile, err := ioutil.TempFile("", "seals-ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(caFile.Name())
+	pem.Encode(caFile, &pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	caFile.Close()
+
+	url := "wss" + strings.TrimPrefix(srv.URL, "https") + "/ws"
+	config := `{"url":"` + url + `","connNum":"3","header.X-Token":"secret","subprotocols":"seals.v1, seals.v2","caFile":"` + caFile.Name() + `"}`
+	var echoed int64
+	var mu sync.Mutex
+	cli, err := server.NewClient("websocketClient", config, proto, server.HandlerFunc(func(session *server.Session) {
+		defer session.Close()
+		for i := 0; i < 20; i++ {
+			msg := make([]byte, rand.Intn(1000)+1)
+			rand.Read(msg)
+			if err := session.Send(msg); err != nil {
+				t.Errorf("Send err:%v\n", err)
+				return
+			}
+			recv, err := session.Receive()
+			if err != nil {
+				t.Errorf("Receive err:%v\n", err)
+				return
+			}
+			if !bytes.Equal(msg, recv.([]byte)) {
+				t.Errorf("recv msg not equal send msg\n")
+			}
+			mu.Lock()
+			echoed++
+			mu.Unlock()
+		}
+	}))
+	if err != nil {
+		t.Fatalf("New client err:%v\n", err)
+	}
+	cli.Run()
+	if echoed != 3*20 {
+		t.Fatalf("echoed %d msgs, want %d\n", echoed, 3*20)
+	}
+}